@@ -7,6 +7,7 @@ import (
 	authmiddleware "poster/api/middlewares/auth"
 	"poster/internal/database"
 	"poster/internal/lib/mail/sender"
+	"poster/internal/lib/mail/templates"
 )
 
 const label = "user"
@@ -15,7 +16,8 @@ type Handler struct {
 	logger   *slog.Logger
 	query    *database.Queries
 	validate *validator.Validate
-	mailer   *sender.Sender
+	mailer   sender.MailSender
+	emails   *templates.Registry
 }
 
 func RegisterRoutes(r chi.Router, handler *Handler) {
@@ -27,11 +29,12 @@ func RegisterRoutes(r chi.Router, handler *Handler) {
 	})
 }
 
-func NewAuthHandler(log *slog.Logger, db *database.Queries, mailer *sender.Sender) *Handler {
+func NewAuthHandler(log *slog.Logger, db *database.Queries, mailer sender.MailSender, emails *templates.Registry) *Handler {
 	return &Handler{
 		logger:   log,
 		query:    db,
 		validate: validator.New(),
 		mailer:   mailer,
+		emails:   emails,
 	}
 }
@@ -12,9 +12,15 @@ import (
 	"poster/internal/lib/http/response"
 	"poster/internal/lib/logger/sl"
 	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/metrics"
 	"time"
 )
 
+// suspended reports whether the account is currently barred from signing in.
+func suspended(u database.User) bool {
+	return u.SuspendedUntil.Valid && u.SuspendedUntil.Time.After(time.Now())
+}
+
 type userLoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
@@ -24,10 +30,10 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	const op = "auth.Login"
 	var req userLoginRequest
 
-	h.logger.Debug("Incoming login request", slog.String("op", op))
+	h.logger.DebugContext(r.Context(), "Incoming login request", slog.String("op", op))
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -35,27 +41,31 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	if err := h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
 
 	u, err := h.query.GetUserByEmail(r.Context(), req.Email)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		metrics.Logins.WithLabelValues("failure").Inc()
+	}
+
 	if err != nil {
 		errD := sqlhelpers.GetDBError(err, label)
-		h.logger.Error("Failed to find user", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to find user", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if !u.IsVerified.Bool {
-		h.logger.Warn("User is not verified", slog.String("op", op), slog.String("email", u.Email))
+		h.logger.WarnContext(r.Context(), "User is not verified", slog.String("op", op), slog.String("email", u.Email))
 		json.WriteJSON(w, http.StatusForbidden, response.ErrorResp{
 			Status:     "error",
 			StatusCode: http.StatusForbidden,
@@ -65,21 +75,29 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err = auth.CheckPasswordHash(req.Password, u.PasswordHash); err != nil {
-		h.logger.Warn("Invalid password attempt", slog.String("op", op), slog.String("email", u.Email))
+		h.logger.WarnContext(r.Context(), "Invalid password attempt", slog.String("op", op), slog.String("email", u.Email))
+		metrics.Logins.WithLabelValues("failure").Inc()
 		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Invalid email or password"))
 		return
 	}
 
+	if suspended(u) {
+		h.logger.WarnContext(r.Context(), "Suspended user login attempt", slog.String("op", op), slog.String("email", u.Email))
+		errD := response.Suspended(u.SuspensionReason, u.SuspendedUntil.Time)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
 	accessToken, err := auth.GenerateAccessToken(u.ID.String())
 	if err != nil {
-		h.logger.Error("Failed to generate access token", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to generate access token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to generate token"))
 		return
 	}
 
 	refreshToken, err := auth.GenerateRefreshToken(u.ID.String())
 	if err != nil {
-		h.logger.Error("Failed to generate refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to generate refresh token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to generate token"))
 		return
 	}
@@ -90,7 +108,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		h.logger.Error("Failed to update refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to update refresh token", slog.String("op", op), sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -118,6 +136,8 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		Expires:  time.Now().Add(7 * 24 * time.Hour),
 	})
 
+	metrics.Logins.WithLabelValues("success").Inc()
+
 	json.WriteJSON(w, http.StatusOK, map[string]any{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
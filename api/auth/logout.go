@@ -16,14 +16,14 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := r.Context().Value("user_id").(string)
 	if !ok || userID == "" {
-		h.logger.Warn("Unauthorized logout attempt", slog.String("op", op))
+		h.logger.WarnContext(r.Context(), "Unauthorized logout attempt", slog.String("op", op))
 		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Invalid token"))
 		return
 	}
 
 	uID, err := uuid.Parse(userID)
 	if err != nil {
-		h.logger.Warn("Invalid user ID format", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid user ID format", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to logout"))
 		return
 	}
@@ -34,7 +34,7 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		h.logger.Error("Failed to logout user", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to logout user", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to logout"))
 		return
 	}
@@ -30,7 +30,7 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		refreshToken = cookie.Value
 	} else {
 		if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-			h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, details.StatusCode, details)
 			return
 		}
@@ -38,7 +38,7 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if refreshToken == "" {
-		h.logger.Warn("Missing refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Missing refresh token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Session expired, please login again"))
 		return
 	}
@@ -46,34 +46,47 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	claims, err := auth.VerifyToken(refreshToken)
 	if err != nil {
 		if strings.Contains(err.Error(), "token expired") {
-			h.logger.Warn("Refresh token expired", slog.String("op", op))
+			h.logger.WarnContext(r.Context(), "Refresh token expired", slog.String("op", op))
 			json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Refresh token expired, please login again"))
 			return
 		}
 
-		h.logger.Warn("Invalid refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid refresh token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Invalid refresh token"))
 		return
 	}
 
 	uId, err := uuid.Parse(claims.UserID)
 	if err != nil {
-		h.logger.Warn("Invalid user ID", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid user ID", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Invalid user ID"))
 		return
 	}
 
 	u, err := h.query.GetUserByUUID(r.Context(), uId)
 	if err != nil {
-		h.logger.Warn("Invalid user query", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid user query", slog.String("op", op), sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, http.StatusUnauthorized, errD)
 		return
 	}
 
+	if !u.RefreshToken.Valid || u.RefreshToken.String != refreshToken {
+		h.logger.WarnContext(r.Context(), "Refresh token revoked", slog.String("op", op), slog.String("user_id", u.ID.String()))
+		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("Session revoked, please login again"))
+		return
+	}
+
+	if suspended(u) {
+		h.logger.WarnContext(r.Context(), "Suspended user refresh attempt", slog.String("op", op), slog.String("user_id", u.ID.String()))
+		errD := response.Suspended(u.SuspensionReason, u.SuspendedUntil.Time)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
 	accessToken, err := auth.GenerateAccessToken(u.ID.String())
 	if err != nil {
-		h.logger.Error("Failed to generate access token", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to generate access token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to generate token"))
 		return
 	}
@@ -81,7 +94,7 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	newRefreshToken, err := auth.GenerateRefreshToken(u.ID.String())
 	if err != nil {
 
-		h.logger.Error("Failed to generate refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.ErrorContext(r.Context(), "Failed to generate refresh token", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to generate token"))
 		return
 	}
@@ -92,7 +105,7 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		h.logger.Warn("Invalid update user refresh token", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid update user refresh token", slog.String("op", op), sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, http.StatusUnauthorized, errD)
 		return
@@ -0,0 +1,164 @@
+package bounces
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"mime"
+	"net/http"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/mail/dsn"
+	"poster/internal/mailqueue"
+	"strings"
+)
+
+const maxReportSize = 1 << 20
+
+// Handler receives bounces and complaints from the mail provider and stops
+// mailing the affected addresses.
+type Handler struct {
+	logger   *slog.Logger
+	query    *database.Queries
+	validate *validator.Validate
+	secret   string
+}
+
+// event is the JSON form for providers that report bounces themselves.
+type event struct {
+	Type   string `json:"type" validate:"required,oneof=bounce complaint"`
+	Email  string `json:"email" validate:"required,email"`
+	Detail string `json:"detail"`
+}
+
+type webhookResponse struct {
+	Suppressed int `json:"suppressed"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Post("/webhooks/mail", handler.Webhook)
+}
+
+func NewBouncesHandler(log *slog.Logger, db *database.Queries, secret string) *Handler {
+	return &Handler{
+		logger:   log,
+		query:    db,
+		validate: validator.New(),
+		secret:   secret,
+	}
+}
+
+// Webhook accepts a JSON event, a bare message/delivery-status body or a
+// whole multipart/report message (a DSN or an ARF complaint). Callers
+// authenticate with the X-Webhook-Secret header.
+func (h *Handler) Webhook(w http.ResponseWriter, r *http.Request) {
+	const op = "bounces.Webhook"
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Secret")), []byte(h.secret)) != 1 {
+		json.WriteJSON(w, http.StatusUnauthorized, response.Unauthorized("invalid webhook secret"))
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		h.event(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReportSize)
+
+	var (
+		report *dsn.Report
+		err    error
+	)
+
+	if mediaType == "message/delivery-status" {
+		report, err = dsn.ParseStatus(r.Body)
+	} else {
+		report, err = dsn.Parse(r.Body)
+	}
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to parse mail report", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid delivery status or feedback report"))
+		return
+	}
+
+	suppressed := 0
+
+	for _, rcpt := range report.Recipients {
+		reason, detail := "", ""
+
+		switch {
+		case report.Complaint():
+			reason, detail = mailqueue.ReasonComplained, report.FeedbackType
+		case report.Type == dsn.TypeDeliveryStatus && rcpt.Bounced():
+			reason, detail = mailqueue.ReasonBounced, strings.TrimSpace(rcpt.Status+" "+rcpt.Diagnostic)
+		default:
+			continue
+		}
+
+		if !h.suppress(w, r, op, rcpt.Email, reason, detail) {
+			return
+		}
+
+		suppressed++
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(webhookResponse{Suppressed: suppressed}))
+}
+
+func (h *Handler) event(w http.ResponseWriter, r *http.Request) {
+	const op = "bounces.event"
+
+	var req event
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	reason := mailqueue.ReasonBounced
+	if req.Type == "complaint" {
+		reason = mailqueue.ReasonComplained
+	}
+
+	if !h.suppress(w, r, op, req.Email, reason, req.Detail) {
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(webhookResponse{Suppressed: 1}))
+}
+
+// suppress stores the suppression. On failure the error response is already
+// written.
+func (h *Handler) suppress(w http.ResponseWriter, r *http.Request, op, email, reason, detail string) bool {
+	if err := mailqueue.Suppress(r.Context(), h.query, email, reason, detail); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to suppress email", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("failed to suppress email"))
+		return false
+	}
+
+	h.logger.InfoContext(r.Context(), "Email suppressed", slog.String("op", op), slog.String("email", email), slog.String("reason", reason))
+
+	return true
+}
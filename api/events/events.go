@@ -0,0 +1,178 @@
+package events
+
+import (
+	encjson "encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/events"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxWatchedPosts caps how many posts one connection may follow.
+const maxWatchedPosts = 20
+
+type Handler struct {
+	logger    *slog.Logger
+	query     *database.Queries
+	hub       *events.Hub
+	heartbeat time.Duration
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.With(authmiddleware.JWTAuthRequired).Get("/events", handler.Stream)
+}
+
+func NewEventsHandler(log *slog.Logger, db *database.Queries, hub *events.Hub, heartbeat time.Duration) *Handler {
+	return &Handler{
+		logger:    log,
+		query:     db,
+		hub:       hub,
+		heartbeat: heartbeat,
+	}
+}
+
+// Stream sends the caller's notifications and activity on the posts listed in
+// ?posts= as Server-Sent Events until the client goes away or the server
+// shuts down.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	const op = "events.Stream"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	topics, ok := h.topics(w, r, op, userId)
+
+	if !ok {
+		return
+	}
+
+	var lastID uint64
+
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		if lastID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+
+	// The server write timeout is meant for regular requests, not streams.
+	if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.WarnContext(r.Context(), "Cannot clear write deadline", slog.String("op", op), sl.Err(err))
+	}
+
+	sub, missed := h.hub.Subscribe(topics, lastID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range missed {
+		if err = writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+
+	if err = rc.Flush(); err != nil {
+		h.logger.WarnContext(r.Context(), "Streaming unsupported", slog.String("op", op), sl.Err(err))
+		return
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case ev, open := <-sub.C:
+			if !open {
+				return
+			}
+
+			if err = writeEvent(w, ev); err != nil {
+				h.logger.WarnContext(r.Context(), "Failed to write event", slog.String("op", op), sl.Err(err))
+				return
+			}
+		}
+
+		if err = rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// topics returns the caller's own topic plus one per watched post the caller
+// is allowed to see. On failure the error response is already written.
+func (h *Handler) topics(w http.ResponseWriter, r *http.Request, op string, userId uuid.UUID) ([]string, bool) {
+	topics := []string{events.UserTopic(userId.String())}
+
+	raw := r.URL.Query().Get("posts")
+
+	if raw == "" {
+		return topics, true
+	}
+
+	ids := strings.Split(raw, ",")
+
+	if len(ids) > maxWatchedPosts {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(fmt.Sprintf("at most %d posts can be watched", maxWatchedPosts)))
+		return nil, false
+	}
+
+	for _, idAlias := range ids {
+		postId, err := uuid.Parse(strings.TrimSpace(idAlias))
+
+		if err != nil {
+			json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid post id"))
+			return nil, false
+		}
+
+		_, err = h.query.GetPost(r.Context(), database.GetPostParams{PostID: postId, UserID: userId})
+
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "Watched post lookup failed", slog.String("op", op), sl.Err(err))
+			errD := sqlhelpers.GetDBError(err, "post")
+			json.WriteJSON(w, errD.StatusCode, errD)
+			return nil, false
+		}
+
+		topics = append(topics, events.PostTopic(postId.String()))
+	}
+
+	return topics, true
+}
+
+func writeEvent(w http.ResponseWriter, ev events.Event) error {
+	data, err := encjson.Marshal(ev.Data)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+
+	return err
+}
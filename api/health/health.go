@@ -0,0 +1,54 @@
+package health
+
+import (
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"poster/internal/buildinfo"
+	"poster/internal/health"
+	"poster/internal/lib/http/json"
+)
+
+// Handler serves the probes and build information. Orchestrators read the
+// status code, so the bodies are plain JSON without the response envelope.
+type Handler struct {
+	logger  *slog.Logger
+	checker *health.Checker
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Get("/healthz", handler.Healthz)
+	r.Get("/readyz", handler.Readyz)
+	r.Get("/version", handler.Version)
+}
+
+func NewHealthHandler(log *slog.Logger, checker *health.Checker) *Handler {
+	return &Handler{
+		logger:  log,
+		checker: checker,
+	}
+}
+
+// Healthz reports that the process is alive; it checks no dependencies.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	json.WriteJSON(w, http.StatusOK, health.Report{Status: health.StatusUp, Checks: map[string]health.Result{}})
+}
+
+// Readyz reports whether the service can take traffic.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	const op = "health.Readyz"
+
+	report := h.checker.Run(r.Context())
+
+	if report.Status != health.StatusUp {
+		h.logger.WarnContext(r.Context(), "Not ready", slog.String("op", op), slog.Any("checks", report.Checks))
+		json.WriteJSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	json.WriteJSON(w, http.StatusOK, buildinfo.Get())
+}
@@ -1,17 +1,23 @@
 package interactions
 
 import (
+	"database/sql"
 	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"log/slog"
 	"net/http"
 	authmiddleware "poster/api/middlewares/auth"
+	"poster/api/moderation"
 	"poster/internal/database"
+	"poster/internal/lib/contentfilter"
 	"poster/internal/lib/http/json"
 	"poster/internal/lib/http/response"
 	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/markdown"
 	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/mentions"
+	"poster/internal/notify"
 	"time"
 )
 
@@ -20,12 +26,17 @@ type commentRequest struct {
 	Content string `json:"content" validate:"required"`
 }
 
+type commentResponse struct {
+	database.Comment
+	Mentions []mentions.Span `json:"mentions"`
+}
+
 func (h *Handler) Comment(w http.ResponseWriter, r *http.Request) {
 	const op = "interactions.comments.Comment"
 	var req commentRequest
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -33,12 +44,12 @@ func (h *Handler) Comment(w http.ResponseWriter, r *http.Request) {
 	if err := h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
@@ -46,7 +57,7 @@ func (h *Handler) Comment(w http.ResponseWriter, r *http.Request) {
 	postId, err := uuid.Parse(req.PostId)
 
 	if err != nil {
-		h.logger.Warn("Invalid post id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid post id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest("invalid post id")
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -59,30 +70,92 @@ func (h *Handler) Comment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comment, err := h.query.CreateComment(r.Context(), database.CreateCommentParams{
-		ID:        uuid.New(),
-		PostID:    postId,
-		UserID:    currentUserId,
-		IsEdited:  false,
-		Content:   req.Content,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	postAuthorId, ok := h.canInteractWithPost(w, r, op, postId, currentUserId)
+
+	if !ok {
+		return
+	}
+
+	verdict, ok := moderation.Screen(w, r, h.logger, op, h.filter, contentfilter.Content{AuthorID: currentUserId, Text: req.Content})
+
+	if !ok {
+		return
+	}
+
+	tx, q, err := h.txs.Begin(r.Context())
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to begin transaction", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to create comment"))
+		return
+	}
+
+	defer tx.Rollback()
+
+	comment, err := q.CreateComment(r.Context(), database.CreateCommentParams{
+		ID:          uuid.New(),
+		PostID:      postId,
+		UserID:      currentUserId,
+		IsEdited:    false,
+		Content:     req.Content,
+		ContentHtml: markdown.Render(req.Content),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		HiddenAt:    sql.NullTime{Time: time.Now(), Valid: verdict.Action == contentfilter.Hold},
 	})
 
 	if err != nil {
-		h.logger.Warn("Failed to create comment", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to create comment", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, "post")
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if comment.ID == uuid.Nil {
-		h.logger.Warn("Attempt to comment on non-existent post", slog.String("op", op))
+		h.logger.WarnContext(r.Context(), "Attempt to comment on non-existent post", slog.String("op", op))
 		json.WriteJSON(w, http.StatusNotFound, response.NotFound("post does not exist"))
 		return
 	}
 
-	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(comment, "comment successfully created"))
+	if verdict.Action == contentfilter.Hold && !moderation.HoldCreated(w, r, h.logger, q, op, moderation.TargetComment, comment.ID, verdict) {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to commit comment", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	mentioned, err := h.mentioner.SaveComment(r.Context(), comment.ID, req.Content)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to save mentions", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, "mention")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := commentResponse{Comment: comment, Mentions: mentioned.Spans}
+
+	if verdict.Action == contentfilter.Hold {
+		json.WriteJSON(w, http.StatusAccepted, response.OkWDataAMsg(res, "comment held for review"))
+		return
+	}
+
+	h.notifier.Notify(r.Context(), notify.Event{
+		Type:      notify.TypeComment,
+		Recipient: postAuthorId,
+		Actor:     currentUserId,
+		TargetID:  postId,
+	})
+	h.mentioner.Notify(r.Context(), currentUserId, postId, mentioned.Added)
+
+	h.publish(postId, EventComment, comment)
+	h.publish(postId, EventCommentCreated, res)
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(res, "comment successfully created"))
 }
 
 func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
@@ -92,14 +165,14 @@ func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -107,12 +180,12 @@ func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	if err = h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
@@ -120,7 +193,7 @@ func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	postId, err := uuid.Parse(req.PostId)
 
 	if err != nil {
-		h.logger.Warn("Invalid post id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid post id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest("invalid post id")
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -133,21 +206,73 @@ func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedComment, err := h.query.UpdateComment(r.Context(), database.UpdateCommentParams{
-		ID:      commentID,
-		PostID:  postId,
-		UserID:  currentUserId,
-		Content: req.Content,
+	verdict, ok := moderation.Screen(w, r, h.logger, op, h.filter, contentfilter.Content{
+		AuthorID:  currentUserId,
+		ExcludeID: commentID,
+		Text:      req.Content,
+	})
+
+	if !ok {
+		return
+	}
+
+	tx, q, err := h.txs.Begin(r.Context())
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to begin transaction", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to update comment"))
+		return
+	}
+
+	defer tx.Rollback()
+
+	updatedComment, err := q.UpdateComment(r.Context(), database.UpdateCommentParams{
+		ID:          commentID,
+		PostID:      postId,
+		UserID:      currentUserId,
+		Content:     req.Content,
+		ContentHtml: markdown.Render(req.Content),
 	})
 
 	if err != nil {
-		h.logger.Warn("Failed to update comment", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to update comment", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if verdict.Action == contentfilter.Hold && !moderation.Hold(w, r, h.logger, q, op, moderation.TargetComment, updatedComment.ID, verdict) {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to commit comment", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, commentLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
-	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(updatedComment, "comment successfully updated"))
+	mentioned, err := h.mentioner.SaveComment(r.Context(), updatedComment.ID, req.Content)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to save mentions", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, "mention")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := commentResponse{Comment: updatedComment, Mentions: mentioned.Spans}
+
+	if verdict.Action == contentfilter.Hold {
+		json.WriteJSON(w, http.StatusAccepted, response.OkWDataAMsg(res, "comment held for review"))
+		return
+	}
+
+	h.mentioner.Notify(r.Context(), currentUserId, updatedComment.PostID, mentioned.Added)
+
+	h.publish(updatedComment.PostID, EventCommentUpdated, res)
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(res, "comment successfully updated"))
 }
 
 type deleteCommentRequest struct {
@@ -161,14 +286,14 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -176,12 +301,12 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	if err = h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
@@ -189,7 +314,7 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	postId, err := uuid.Parse(req.PostId)
 
 	if err != nil {
-		h.logger.Warn("Invalid post id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid post id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest("invalid post id")
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -203,23 +328,26 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	deletedRows, err := h.query.DeleteComment(r.Context(), database.DeleteCommentParams{
-		ID:     commentID,
-		PostID: postId,
-		UserID: currentUserId,
+		ID:        commentID,
+		PostID:    postId,
+		UserID:    currentUserId,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
 	})
 
 	if err != nil {
-		h.logger.Warn("Failed to update comment", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to update comment", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, commentLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if deletedRows == 0 {
-		h.logger.Error("Attempt to delete non-existent comment", slog.String("op", op))
+		h.logger.ErrorContext(r.Context(), "Attempt to delete non-existent comment", slog.String("op", op))
 		json.WriteJSON(w, http.StatusNotFound, response.NotFound(errCommentAttachmentNotFound.Error()))
 		return
 	}
 
+	h.publish(postId, EventCommentDeleted, commentDeletedEvent{PostID: postId, CommentID: commentID})
+
 	json.WriteJSON(w, http.StatusOK, response.OkWMsg("comment successfully deleted"))
 }
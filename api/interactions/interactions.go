@@ -1,30 +1,48 @@
 package interactions
 
 import (
-	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"log/slog"
+	"net/http"
 	authmiddleware "poster/api/middlewares/auth"
 	"poster/internal/database"
+	"poster/internal/events"
+	"poster/internal/lib/contentfilter"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/reactions"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/mentions"
+	"poster/internal/notify"
+	"poster/internal/txn"
+	"time"
 )
 
 const commentLabel = "comment"
 const postLabel = "post"
 
 var (
-	errCommentNotFound           = errors.New("comment not found")
-	errPostNotFound              = errors.New("post not found")
 	errPostAttachmentNotFound    = errors.New("post attachment not found")
 	errCommentAttachmentNotFound = errors.New("comment attachment not found")
+	errReactionNotAllowed        = errors.New("reaction is not allowed")
+	errBlocked                   = errors.New("you have been blocked by the author")
 )
 
 type Handler struct {
-	logger   *slog.Logger
-	query    *database.Queries
-	validate *validator.Validate
+	logger    *slog.Logger
+	query     *database.Queries
+	txs       *txn.Runner
+	validate  *validator.Validate
+	reactions *reactions.AllowList
+	retention time.Duration
+	filter    *contentfilter.Pipeline
+	notifier  *notify.Notifier
+	hub       *events.Hub
+	mentioner *mentions.Mentioner
 }
 
 func RegisterRoutes(r chi.Router, handler *Handler) {
@@ -32,34 +50,92 @@ func RegisterRoutes(r chi.Router, handler *Handler) {
 		r.Route("/post", func(r chi.Router) {
 			r.With(authmiddleware.JWTAuthRequired).Post("/like/{id}", handler.LikePost)
 			r.With(authmiddleware.JWTAuthRequired).Post("/unlike/{id}", handler.UnlikePost)
+			r.With(authmiddleware.JWTAuthNotRequired).Get("/{id}/likes", handler.GetPostLikers)
+
+			r.With(authmiddleware.JWTAuthRequired).Put("/{id}/reactions/{emoji}", handler.SetPostReaction)
+			r.With(authmiddleware.JWTAuthRequired).Delete("/{id}/reactions/{emoji}", handler.RemovePostReaction)
 		})
 
 		r.Route("/comment", func(r chi.Router) {
 			r.With(authmiddleware.JWTAuthRequired).Post("/", handler.Comment)
 			r.With(authmiddleware.JWTAuthRequired).Put("/{id}", handler.UpdateComment)
 			r.With(authmiddleware.JWTAuthRequired).Delete("/{id}", handler.DeleteComment)
+			r.With(authmiddleware.JWTAuthRequired).Post("/{id}/restore", handler.RestoreComment)
 
 			r.With(authmiddleware.JWTAuthRequired).Post("/like/{id}", handler.LikeComment)
 			r.With(authmiddleware.JWTAuthRequired).Post("/unlike/{id}", handler.UnlikeComment)
+			r.With(authmiddleware.JWTAuthNotRequired).Get("/{id}/likes", handler.GetCommentLikers)
+
+			r.With(authmiddleware.JWTAuthRequired).Put("/{id}/reactions/{emoji}", handler.SetCommentReaction)
+			r.With(authmiddleware.JWTAuthRequired).Delete("/{id}/reactions/{emoji}", handler.RemoveCommentReaction)
 		})
 	})
 
 }
 
-func NewInteractionsHandlers(log *slog.Logger, db *database.Queries) *Handler {
+func NewInteractionsHandlers(log *slog.Logger, txs *txn.Runner, db *database.Queries, allowed *reactions.AllowList, retention time.Duration, filter *contentfilter.Pipeline, notifier *notify.Notifier, hub *events.Hub, mentioner *mentions.Mentioner) *Handler {
 	return &Handler{
-		logger:   log,
-		query:    db,
-		validate: validator.New(),
+		logger:    log,
+		query:     db,
+		txs:       txs,
+		validate:  validator.New(),
+		reactions: allowed,
+		retention: retention,
+		filter:    filter,
+		notifier:  notifier,
+		hub:       hub,
+		mentioner: mentioner,
+	}
+}
+
+// canInteractWithPost checks that the post exists, is not hidden and its
+// author has not blocked userID, and returns the author. On failure the error
+// response is already written.
+func (h *Handler) canInteractWithPost(w http.ResponseWriter, r *http.Request, op string, postID, userID uuid.UUID) (uuid.UUID, bool) {
+	post, err := h.query.GetVisiblePost(r.Context(), postID)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "post lookup failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, postLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, false
 	}
+
+	return post.AuthorID, h.ensureNotBlocked(w, r, op, post.AuthorID, userID)
 }
 
-func (h *Handler) isCommentExist(ctx context.Context, id uuid.UUID) bool {
-	_, err := h.query.GetComment(ctx, id)
-	return err == nil
+// canInteractWithComment is canInteractWithPost for comments. It returns the
+// whole comment.
+func (h *Handler) canInteractWithComment(w http.ResponseWriter, r *http.Request, op string, commentID, userID uuid.UUID) (database.Comment, bool) {
+	comment, err := h.query.GetVisibleComment(r.Context(), commentID)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "comment lookup failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return database.Comment{}, false
+	}
+
+	return comment, h.ensureNotBlocked(w, r, op, comment.UserID, userID)
 }
 
-func (h *Handler) isPostExist(ctx context.Context, id uuid.UUID) bool {
-	_, err := h.query.GetPost(ctx, id)
-	return err == nil
+func (h *Handler) ensureNotBlocked(w http.ResponseWriter, r *http.Request, op string, ownerID, userID uuid.UUID) bool {
+	blocked, err := h.query.IsBlocked(r.Context(), database.IsBlockedParams{
+		BlockerID: ownerID,
+		BlockedID: userID,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "block lookup failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "block")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return false
+	}
+
+	if blocked {
+		json.WriteJSON(w, http.StatusForbidden, response.Forbidden(errBlocked.Error()))
+		return false
+	}
+
+	return true
 }
@@ -0,0 +1,142 @@
+package interactions
+
+import (
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+type liker struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	LikedAt  time.Time `json:"liked_at"`
+}
+
+type likersResponse struct {
+	Users []liker `json:"users"`
+	Total int64   `json:"total"`
+	pagination.Page
+}
+
+func (h *Handler) GetPostLikers(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.GetPostLikers"
+
+	postID, err := h.isValidUUIDParam(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid post id", slog.String("op", op), sl.Err(err))
+		errD := response.BadRequest(err.Error())
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	// Anonymous viewers are identified as uuid.Nil, which nobody can block.
+	viewerId, _, _ := authmiddleware.Identify(r, w, h.logger, op)
+
+	if _, ok := h.canInteractWithPost(w, r, op, postID, viewerId); !ok {
+		return
+	}
+
+	rows, err := h.query.GetPostLikers(r.Context(), database.GetPostLikersParams{
+		PostID:     postID,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "failed to get post likers", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, postLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	total, err := h.query.CountPostLikes(r.Context(), postID)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "failed to count post likes", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, postLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := likersResponse{Users: make([]liker, 0, len(rows)), Total: total, Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, liker{ID: row.ID, Username: row.Username, LikedAt: row.LikedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
+
+func (h *Handler) GetCommentLikers(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.GetCommentLikers"
+
+	commentID, err := h.isValidUUIDParam(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
+		errD := response.BadRequest(err.Error())
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	viewerId, _, _ := authmiddleware.Identify(r, w, h.logger, op)
+
+	if _, ok := h.canInteractWithComment(w, r, op, commentID, viewerId); !ok {
+		return
+	}
+
+	rows, err := h.query.GetCommentLikers(r.Context(), database.GetCommentLikersParams{
+		CommentID:  commentID,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "failed to get comment likers", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	total, err := h.query.CountCommentLikes(r.Context(), commentID)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "failed to count comment likes", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := likersResponse{Users: make([]liker, 0, len(rows)), Total: total, Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, liker{ID: row.ID, Username: row.Username, LikedAt: row.LikedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
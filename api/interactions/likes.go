@@ -11,7 +11,9 @@ import (
 	"poster/internal/lib/http/json"
 	"poster/internal/lib/http/response"
 	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/reactions"
 	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/notify"
 	"time"
 )
 
@@ -32,13 +34,21 @@ func (h *Handler) isValidUUIDParam(r *http.Request) (uuid.UUID, error) {
 	return id, nil
 }
 
+func errAlreadyLiked(label string) response.ErrorResp {
+	return response.ErrorResp{
+		Status:     response.StatusError,
+		StatusCode: http.StatusConflict,
+		Message:    label + " is already liked",
+	}
+}
+
 func (h *Handler) LikeComment(w http.ResponseWriter, r *http.Request) {
 	const op = "interactions.LikeComment"
 
 	commentID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -51,34 +61,47 @@ func (h *Handler) LikeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok := h.isCommentExist(r.Context(), commentID); !ok {
-		h.logger.Warn("attempt to like non-existent comment", slog.String("op", op))
-		errD = response.NotFound(errCommentNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+	comment, ok := h.canInteractWithComment(w, r, op, commentID, currentUserId)
+
+	if !ok {
 		return
 	}
 
-	likedRows, err := h.query.LikeComment(r.Context(), database.LikeCommentParams{
+	likedRows, err := h.query.AddCommentReaction(r.Context(), database.AddCommentReactionParams{
 		ID:        uuid.New(),
 		UserID:    currentUserId,
 		CommentID: commentID,
+		Emoji:     reactions.Like,
 		CreatedAt: time.Now(),
 	})
 
 	if err != nil {
-		h.logger.Warn("like failed", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "like failed", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, commentLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if likedRows == 0 {
-		h.logger.Warn("attempt to like non-existent comment", slog.String("op", op))
-		errD = response.NotFound(errCommentAttachmentNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+		h.logger.WarnContext(r.Context(), "attempt to like comment twice", slog.String("op", op))
+		json.WriteJSON(w, http.StatusConflict, errAlreadyLiked(commentLabel))
 		return
 	}
 
+	h.notifier.Notify(r.Context(), notify.Event{
+		Type:      notify.TypeCommentLike,
+		Recipient: comment.UserID,
+		Actor:     currentUserId,
+		TargetID:  commentID,
+	})
+
+	h.publish(comment.PostID, EventCommentLike, likeEvent{
+		PostID:    comment.PostID,
+		CommentID: uuid.NullUUID{UUID: commentID, Valid: true},
+		UserID:    currentUserId,
+	})
+	h.publishCommentLikes(r.Context(), op, comment.PostID, commentID)
+
 	json.WriteJSON(w, http.StatusOK, response.OkWMsg("comment successfully liked"))
 }
 
@@ -88,7 +111,7 @@ func (h *Handler) UnlikeComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -101,32 +124,34 @@ func (h *Handler) UnlikeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok := h.isCommentExist(r.Context(), commentID); !ok {
-		h.logger.Warn("attempt to like non-existent comment", slog.String("op", op))
-		errD = response.NotFound(errCommentNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+	comment, ok := h.canInteractWithComment(w, r, op, commentID, currentUserId)
+
+	if !ok {
 		return
 	}
 
-	likedRows, err := h.query.UnlikeComment(r.Context(), database.UnlikeCommentParams{
+	likedRows, err := h.query.RemoveCommentReaction(r.Context(), database.RemoveCommentReactionParams{
 		UserID:    currentUserId,
 		CommentID: commentID,
+		Emoji:     reactions.Like,
 	})
 
 	if err != nil {
-		h.logger.Warn("unlike failed", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "unlike failed", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, commentLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if likedRows == 0 {
-		h.logger.Warn("attempt to like non-existent comment", slog.String("op", op))
+		h.logger.WarnContext(r.Context(), "attempt to like non-existent comment", slog.String("op", op))
 		errD = response.NotFound(errCommentAttachmentNotFound.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
+	h.publishCommentLikes(r.Context(), op, comment.PostID, commentID)
+
 	json.WriteJSON(w, http.StatusOK, response.OkWMsg("comment successfully unliked"))
 }
 
@@ -136,7 +161,7 @@ func (h *Handler) LikePost(w http.ResponseWriter, r *http.Request) {
 	postID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -149,34 +174,43 @@ func (h *Handler) LikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok := h.isPostExist(r.Context(), postID); !ok {
-		h.logger.Warn("attempt to like non-existent post", slog.String("op", op))
-		errD = response.NotFound(errPostNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+	authorId, ok := h.canInteractWithPost(w, r, op, postID, currentUserId)
+
+	if !ok {
 		return
 	}
 
-	likedRows, err := h.query.LikePost(r.Context(), database.LikePostParams{
+	likedRows, err := h.query.AddPostReaction(r.Context(), database.AddPostReactionParams{
 		ID:        uuid.New(),
 		UserID:    currentUserId,
 		PostID:    postID,
+		Emoji:     reactions.Like,
 		CreatedAt: time.Now(),
 	})
 
 	if err != nil {
-		h.logger.Warn("like failed", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "like failed", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, postLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if likedRows == 0 {
-		h.logger.Warn("attempt to like non-existent post", slog.String("op", op))
-		errD = response.NotFound(errPostAttachmentNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+		h.logger.WarnContext(r.Context(), "attempt to like post twice", slog.String("op", op))
+		json.WriteJSON(w, http.StatusConflict, errAlreadyLiked(postLabel))
 		return
 	}
 
+	h.notifier.Notify(r.Context(), notify.Event{
+		Type:      notify.TypePostLike,
+		Recipient: authorId,
+		Actor:     currentUserId,
+		TargetID:  postID,
+	})
+
+	h.publish(postID, EventLike, likeEvent{PostID: postID, UserID: currentUserId})
+	h.publishPostLikes(r.Context(), op, postID)
+
 	json.WriteJSON(w, http.StatusOK, response.OkWMsg("post successfully liked"))
 }
 
@@ -186,7 +220,7 @@ func (h *Handler) UnlikePost(w http.ResponseWriter, r *http.Request) {
 	postID, err := h.isValidUUIDParam(r)
 
 	if err != nil {
-		h.logger.Warn("invalid comment id", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
 		errD := response.BadRequest(err.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -199,31 +233,31 @@ func (h *Handler) UnlikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok := h.isPostExist(r.Context(), postID); !ok {
-		h.logger.Warn("attempt to like non-existent post", slog.String("op", op))
-		errD = response.NotFound(errPostNotFound.Error())
-		json.WriteJSON(w, errD.StatusCode, errD)
+	if _, ok := h.canInteractWithPost(w, r, op, postID, currentUserId); !ok {
 		return
 	}
 
-	likedRows, err := h.query.UnlikePost(r.Context(), database.UnlikePostParams{
+	likedRows, err := h.query.RemovePostReaction(r.Context(), database.RemovePostReactionParams{
 		UserID: currentUserId,
 		PostID: postID,
+		Emoji:  reactions.Like,
 	})
 
 	if err != nil {
-		h.logger.Warn("unlike failed", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "unlike failed", slog.String("op", op), sl.Err(err))
 		errD = sqlhelpers.GetDBError(err, postLabel)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
 	if likedRows == 0 {
-		h.logger.Warn("attempt to like non-existent post", slog.String("op", op))
+		h.logger.WarnContext(r.Context(), "attempt to like non-existent post", slog.String("op", op))
 		errD = response.NotFound(errPostAttachmentNotFound.Error())
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
+	h.publishPostLikes(r.Context(), op, postID)
+
 	json.WriteJSON(w, http.StatusOK, response.OkWMsg("post successfully unliked"))
 }
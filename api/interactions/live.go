@@ -0,0 +1,84 @@
+package interactions
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log/slog"
+	"poster/internal/events"
+	"poster/internal/lib/logger/sl"
+)
+
+// Events published on a post's topic for live clients.
+const (
+	EventCommentCreated = "comment_created"
+	EventCommentUpdated = "comment_updated"
+	EventCommentDeleted = "comment_deleted"
+	EventLikeCount      = "like_count"
+)
+
+// Events of the original SSE stream. They are still published next to the
+// ones above so existing clients keep working.
+const (
+	EventComment     = "comment"
+	EventLike        = "like"
+	EventCommentLike = "comment_like"
+)
+
+type commentDeletedEvent struct {
+	PostID    uuid.UUID `json:"post_id"`
+	CommentID uuid.UUID `json:"comment_id"`
+}
+
+type likeEvent struct {
+	PostID    uuid.UUID     `json:"post_id"`
+	CommentID uuid.NullUUID `json:"comment_id"`
+	UserID    uuid.UUID     `json:"user_id"`
+}
+
+type likeCountEvent struct {
+	PostID    uuid.UUID  `json:"post_id"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	LikeCount int64      `json:"like_count"`
+}
+
+func (h *Handler) publish(postID uuid.UUID, eventType string, data any) {
+	h.hub.Publish(events.PostTopic(postID.String()), eventType, data)
+}
+
+// publishPostLikes sends the current like count of a post. Counting errors
+// only cost the live update, so they are logged.
+func (h *Handler) publishPostLikes(ctx context.Context, op string, postID uuid.UUID) {
+	count, err := h.query.CountPostLikes(ctx, postID)
+
+	if err != nil {
+		h.logger.WarnContext(ctx, "Failed to count post likes", slog.String("op", op), sl.Err(err))
+		return
+	}
+
+	h.publish(postID, EventLikeCount, likeCountEvent{PostID: postID, LikeCount: count})
+}
+
+// publishCommentLikesByID is publishCommentLikes for callers that only know
+// the comment.
+func (h *Handler) publishCommentLikesByID(ctx context.Context, op string, commentID uuid.UUID) {
+	comment, err := h.query.GetComment(ctx, commentID)
+
+	if err != nil {
+		h.logger.WarnContext(ctx, "Failed to get comment", slog.String("op", op), sl.Err(err))
+		return
+	}
+
+	h.publishCommentLikes(ctx, op, comment.PostID, commentID)
+}
+
+// publishCommentLikes is publishPostLikes for comments.
+func (h *Handler) publishCommentLikes(ctx context.Context, op string, postID, commentID uuid.UUID) {
+	count, err := h.query.CountCommentLikes(ctx, commentID)
+
+	if err != nil {
+		h.logger.WarnContext(ctx, "Failed to count comment likes", slog.String("op", op), sl.Err(err))
+		return
+	}
+
+	h.publish(postID, EventLikeCount, likeCountEvent{PostID: postID, CommentID: &commentID, LikeCount: count})
+}
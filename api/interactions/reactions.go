@@ -0,0 +1,235 @@
+package interactions
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"net/url"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/reactions"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/notify"
+	"time"
+)
+
+type reactionResponse struct {
+	Emoji string `json:"emoji"`
+}
+
+// reactionParam returns the canonical form of the {emoji} route param and
+// false when it is not in the allow-list.
+func (h *Handler) reactionParam(r *http.Request) (string, bool) {
+	raw, err := url.PathUnescape(chi.URLParam(r, "emoji"))
+
+	if err != nil {
+		return "", false
+	}
+
+	return h.reactions.Canonical(raw)
+}
+
+// reactionRequest parses the target id, the emoji and the caller. On failure
+// the error response is already written.
+func (h *Handler) reactionRequest(w http.ResponseWriter, r *http.Request, op string) (uuid.UUID, string, uuid.UUID, bool) {
+	targetID, err := h.isValidUUIDParam(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid id", slog.String("op", op), sl.Err(err))
+		errD := response.BadRequest(err.Error())
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, "", uuid.Nil, false
+	}
+
+	emoji, ok := h.reactionParam(r)
+
+	if !ok {
+		h.logger.WarnContext(r.Context(), "reaction not allowed", slog.String("op", op))
+		errD := response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusBadRequest,
+			Message:    errReactionNotAllowed.Error(),
+			Details:    h.reactions.All(),
+		}
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, "", uuid.Nil, false
+	}
+
+	currentUserId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, "", uuid.Nil, false
+	}
+
+	return targetID, emoji, currentUserId, true
+}
+
+func (h *Handler) SetPostReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.SetPostReaction"
+
+	postID, emoji, currentUserId, ok := h.reactionRequest(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	authorId, ok := h.canInteractWithPost(w, r, op, postID, currentUserId)
+
+	if !ok {
+		return
+	}
+
+	added, err := h.query.AddPostReaction(r.Context(), database.AddPostReactionParams{
+		ID:        uuid.New(),
+		UserID:    currentUserId,
+		PostID:    postID,
+		Emoji:     emoji,
+		CreatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "reaction failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, postLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	// A 👍 is a like, whichever endpoint it came through.
+	if emoji == reactions.Like && added > 0 {
+		h.notifier.Notify(r.Context(), notify.Event{
+			Type:      notify.TypePostLike,
+			Recipient: authorId,
+			Actor:     currentUserId,
+			TargetID:  postID,
+		})
+
+		h.publish(postID, EventLike, likeEvent{PostID: postID, UserID: currentUserId})
+		h.publishPostLikes(r.Context(), op, postID)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(reactionResponse{Emoji: emoji}, "reaction added"))
+}
+
+func (h *Handler) RemovePostReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.RemovePostReaction"
+
+	postID, emoji, currentUserId, ok := h.reactionRequest(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	removed, err := h.query.RemovePostReaction(r.Context(), database.RemovePostReactionParams{
+		UserID: currentUserId,
+		PostID: postID,
+		Emoji:  emoji,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "reaction removal failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, postLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if removed == 0 {
+		errD := response.NotFound("reaction not found")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if emoji == reactions.Like {
+		h.publishPostLikes(r.Context(), op, postID)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(reactionResponse{Emoji: emoji}, "reaction removed"))
+}
+
+func (h *Handler) SetCommentReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.SetCommentReaction"
+
+	commentID, emoji, currentUserId, ok := h.reactionRequest(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	comment, ok := h.canInteractWithComment(w, r, op, commentID, currentUserId)
+
+	if !ok {
+		return
+	}
+
+	added, err := h.query.AddCommentReaction(r.Context(), database.AddCommentReactionParams{
+		ID:        uuid.New(),
+		UserID:    currentUserId,
+		CommentID: commentID,
+		Emoji:     emoji,
+		CreatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "reaction failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if emoji == reactions.Like && added > 0 {
+		h.notifier.Notify(r.Context(), notify.Event{
+			Type:      notify.TypeCommentLike,
+			Recipient: comment.UserID,
+			Actor:     currentUserId,
+			TargetID:  commentID,
+		})
+
+		h.publish(comment.PostID, EventCommentLike, likeEvent{
+			PostID:    comment.PostID,
+			CommentID: uuid.NullUUID{UUID: commentID, Valid: true},
+			UserID:    currentUserId,
+		})
+		h.publishCommentLikes(r.Context(), op, comment.PostID, commentID)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(reactionResponse{Emoji: emoji}, "reaction added"))
+}
+
+func (h *Handler) RemoveCommentReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.RemoveCommentReaction"
+
+	commentID, emoji, currentUserId, ok := h.reactionRequest(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	removed, err := h.query.RemoveCommentReaction(r.Context(), database.RemoveCommentReactionParams{
+		UserID:    currentUserId,
+		CommentID: commentID,
+		Emoji:     emoji,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "reaction removal failed", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if removed == 0 {
+		errD := response.NotFound("reaction not found")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if emoji == reactions.Like {
+		h.publishCommentLikesByID(r.Context(), op, commentID)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(reactionResponse{Emoji: emoji}, "reaction removed"))
+}
@@ -0,0 +1,109 @@
+package interactions
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/api/moderation"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+// RestoreComment brings back a soft-deleted or hidden comment, following the
+// same rules as posts.RestorePost.
+func (h *Handler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	const op = "interactions.RestoreComment"
+
+	commentID, err := h.isValidUUIDParam(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid comment id", slog.String("op", op), sl.Err(err))
+		errD := response.BadRequest(err.Error())
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	currentUserId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	comment, err := h.query.GetCommentIncludingDeleted(r.Context(), commentID)
+
+	if err != nil {
+		errD = sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if !comment.DeletedAt.Valid && !comment.HiddenAt.Valid {
+		json.WriteJSON(w, http.StatusConflict, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusConflict,
+			Message:    "comment is not deleted",
+		})
+		return
+	}
+
+	isModerator, err := moderation.IsModerator(r.Context(), h.query, currentUserId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "failed to get user role", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, "user")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	selfDeleted := comment.UserID == currentUserId && comment.DeletedBy.UUID == currentUserId && !comment.HiddenAt.Valid
+
+	if !isModerator && !selfDeleted {
+		json.WriteJSON(w, http.StatusForbidden, response.Forbidden("you cannot restore this comment"))
+		return
+	}
+
+	cutoff := time.Now().Add(-h.retention)
+
+	if comment.DeletedAt.Valid && comment.DeletedAt.Time.Before(cutoff) {
+		json.WriteJSON(w, http.StatusGone, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusGone,
+			Message:    "restore window has expired",
+		})
+		return
+	}
+
+	if _, err = h.query.RestoreComment(r.Context(), database.RestoreCommentParams{
+		ID:           comment.ID,
+		DeletedAfter: sql.NullTime{Time: cutoff, Valid: true},
+	}); err != nil {
+		h.logger.WarnContext(r.Context(), "failed to restore comment", slog.String("op", op), sl.Err(err))
+		errD = sqlhelpers.GetDBError(err, commentLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if !selfDeleted {
+		_, err = h.query.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ID:          uuid.New(),
+			ModeratorID: uuid.NullUUID{UUID: currentUserId, Valid: true},
+			Action:      "restore_" + moderation.TargetComment,
+			TargetType:  moderation.TargetComment,
+			TargetID:    comment.ID,
+			CreatedAt:   time.Now(),
+		})
+
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to record moderation action", slog.String("op", op), sl.Err(err))
+		}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("comment successfully restored"))
+}
@@ -0,0 +1,277 @@
+package live
+
+import (
+	encjson "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+	"log/slog"
+	"net/http"
+	"net/url"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/events"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/logger/sl"
+	"time"
+)
+
+const (
+	// maxMessageSize bounds what a client may send in one frame.
+	maxMessageSize = 4 << 10
+
+	// maxSubscriptions caps how many posts one connection may follow.
+	maxSubscriptions = 50
+
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+	actionPing        = "ping"
+	actionPong        = "pong"
+)
+
+var errBadOrigin = errors.New("cross-origin websocket request")
+
+// Options tune the connection keepalive. The server sends a "ping" message
+// every PingInterval and drops clients that stay silent for PongWait.
+// Writes that take longer than WriteWait mean the client cannot keep up.
+type Options struct {
+	PingInterval time.Duration
+	PongWait     time.Duration
+	WriteWait    time.Duration
+}
+
+type Handler struct {
+	logger *slog.Logger
+	query  *database.Queries
+	hub    *events.Hub
+	opts   Options
+}
+
+// clientMessage is what clients send, e.g.
+// {"action": "subscribe", "post_ids": ["..."]}.
+type clientMessage struct {
+	Action  string   `json:"action"`
+	PostIDs []string `json:"post_ids"`
+}
+
+type serverMessage struct {
+	Type    string   `json:"type"`
+	ID      uint64   `json:"id,omitempty"`
+	Data    any      `json:"data,omitempty"`
+	PostIDs []string `json:"post_ids,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.With(authmiddleware.JWTAuthRequired).Get("/live", handler.Connect)
+}
+
+func NewLiveHandler(log *slog.Logger, db *database.Queries, hub *events.Hub, opts Options) *Handler {
+	return &Handler{
+		logger: log,
+		query:  db,
+		hub:    hub,
+		opts:   opts,
+	}
+}
+
+// Connect upgrades the request to a WebSocket over which the client follows
+// comment and like activity of posts.
+func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	const op = "live.Connect"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = maxMessageSize
+			h.serve(ws, userId)
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// checkOrigin rejects browser requests from other sites, which would
+// otherwise ride on the user's auth cookie. Clients that send no Origin,
+// i.e. non-browser ones, are let through.
+func checkOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+
+	if err != nil || u.Host != r.Host {
+		return errBadOrigin
+	}
+
+	cfg.Origin = u
+
+	return nil
+}
+
+func (h *Handler) serve(ws *websocket.Conn, userId uuid.UUID) {
+	const op = "live.serve"
+
+	defer ws.Close()
+
+	sub, _ := h.hub.Subscribe(nil, 0)
+	defer sub.Cancel()
+
+	// Everything the client sends is handled by the reader; it passes the
+	// replies to the writer, which owns the connection for writing.
+	replies := make(chan serverMessage, 8)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(done)
+		h.read(ws, sub, userId, replies, quit)
+	}()
+
+	ticker := time.NewTicker(h.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var msg serverMessage
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			msg = serverMessage{Type: actionPing}
+		case msg = <-replies:
+		case ev, open := <-sub.C:
+			if !open {
+				// Dropped by the hub for falling behind, or shutting down.
+				h.send(ws, serverMessage{Type: "error", Message: "connection closed by server, please reconnect"})
+				return
+			}
+
+			msg = serverMessage{Type: ev.Type, ID: ev.ID, Data: ev.Data}
+		}
+
+		if err := h.send(ws, msg); err != nil {
+			h.logger.Debug("Closing live connection", slog.String("op", op), sl.Err(err))
+			return
+		}
+	}
+}
+
+func (h *Handler) send(ws *websocket.Conn, msg serverMessage) error {
+	if err := ws.SetWriteDeadline(time.Now().Add(h.opts.WriteWait)); err != nil {
+		return err
+	}
+
+	return websocket.JSON.Send(ws, msg)
+}
+
+// read handles client messages until the connection fails, the client is
+// silent for longer than PongWait or quit is closed.
+func (h *Handler) read(ws *websocket.Conn, sub *events.Subscription, userId uuid.UUID, replies chan<- serverMessage, quit <-chan struct{}) {
+	reply := func(msg serverMessage) bool {
+		select {
+		case replies <- msg:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+
+	for {
+		if err := ws.SetReadDeadline(time.Now().Add(h.opts.PongWait)); err != nil {
+			return
+		}
+
+		var msg clientMessage
+
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			var syntaxErr *encjson.SyntaxError
+			var typeErr *encjson.UnmarshalTypeError
+
+			if (errors.As(err, &syntaxErr) || errors.As(err, &typeErr)) && reply(serverMessage{Type: "error", Message: "malformed message"}) {
+				continue
+			}
+
+			return
+		}
+
+		var res serverMessage
+
+		switch msg.Action {
+		case actionPong:
+			continue
+		case actionPing:
+			res = serverMessage{Type: actionPong}
+		case actionSubscribe:
+			res = h.subscribe(ws, sub, userId, msg.PostIDs)
+		case actionUnsubscribe:
+			res = unsubscribe(sub, msg.PostIDs)
+		default:
+			res = serverMessage{Type: "error", Message: fmt.Sprintf("unknown action %q", msg.Action)}
+		}
+
+		if !reply(res) {
+			return
+		}
+	}
+}
+
+// subscribe adds the posts the user may see to the subscription.
+func (h *Handler) subscribe(ws *websocket.Conn, sub *events.Subscription, userId uuid.UUID, postIDs []string) serverMessage {
+	if sub.Len()+len(postIDs) > maxSubscriptions {
+		return serverMessage{Type: "error", Message: fmt.Sprintf("at most %d posts can be followed", maxSubscriptions)}
+	}
+
+	topics := make([]string, 0, len(postIDs))
+	accepted := make([]string, 0, len(postIDs))
+
+	for _, idAlias := range postIDs {
+		postId, err := uuid.Parse(idAlias)
+
+		if err != nil {
+			return serverMessage{Type: "error", Message: "passed invalid post id"}
+		}
+
+		_, err = h.query.GetPost(ws.Request().Context(), database.GetPostParams{PostID: postId, UserID: userId})
+
+		if err != nil {
+			return serverMessage{Type: "error", Message: "post not found: " + idAlias}
+		}
+
+		topics = append(topics, events.PostTopic(postId.String()))
+		accepted = append(accepted, postId.String())
+	}
+
+	sub.Add(topics...)
+
+	return serverMessage{Type: "subscribed", PostIDs: accepted}
+}
+
+func unsubscribe(sub *events.Subscription, postIDs []string) serverMessage {
+	topics := make([]string, 0, len(postIDs))
+	removed := make([]string, 0, len(postIDs))
+
+	for _, idAlias := range postIDs {
+		if postId, err := uuid.Parse(idAlias); err == nil {
+			topics = append(topics, events.PostTopic(postId.String()))
+			removed = append(removed, postId.String())
+		}
+	}
+
+	sub.Remove(topics...)
+
+	return serverMessage{Type: "unsubscribed", PostIDs: removed}
+}
@@ -0,0 +1,75 @@
+package mailpreview
+
+import (
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/mail/templates"
+)
+
+// Handler renders the transactional emails with sample data so they can be
+// checked in a browser. It is only mounted in development.
+type Handler struct {
+	logger *slog.Logger
+	emails *templates.Registry
+}
+
+type emailsResponse struct {
+	Emails  []string `json:"emails"`
+	Locales []string `json:"locales"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Route("/dev/emails", func(r chi.Router) {
+		r.Get("/", handler.List)
+		r.Get("/{name}", handler.Preview)
+	})
+}
+
+func NewMailPreviewHandler(log *slog.Logger, emails *templates.Registry) *Handler {
+	return &Handler{
+		logger: log,
+		emails: emails,
+	}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	json.WriteJSON(w, http.StatusOK, response.OkWData(emailsResponse{
+		Emails:  h.emails.Names(),
+		Locales: templates.Locales,
+	}))
+}
+
+// Preview writes the email named by {name}. ?locale= picks the language,
+// ?format=text shows the subject and plain text part instead of the HTML one.
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	const op = "mailpreview.Preview"
+
+	name := chi.URLParam(r, "name")
+	sample := templates.Sample(name)
+
+	if sample == nil {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("email not found"))
+		return
+	}
+
+	email, err := h.emails.Render(name, r.URL.Query().Get("locale"), sample)
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to render email", slog.String("op", op), slog.String("name", name), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError(err.Error()))
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Subject: " + email.Subject + "\n\n" + email.Text))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(email.HTML))
+}
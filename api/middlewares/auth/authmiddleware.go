@@ -43,6 +43,20 @@ func JWTAuthRequired(next http.Handler) http.Handler {
 			return
 		}
 
+		suspension, err := suspensionOf(r.Context(), claims.UserID)
+		if err != nil {
+			json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to check account status"))
+			return
+		}
+
+		if suspension != nil {
+			auth.DeleteCookie("access_token", w)
+			auth.DeleteCookie("refresh_token", w)
+			errD := response.Suspended(suspension.Reason, suspension.Until)
+			json.WriteJSON(w, errD.StatusCode, errD)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -66,6 +80,11 @@ func JWTAuthNotRequired(next http.Handler) http.Handler {
 		claims, err := auth.VerifyToken(tokenString)
 
 		if err == nil {
+			if suspension, err := suspensionOf(r.Context(), claims.UserID); err != nil || suspension != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
@@ -79,7 +98,7 @@ func Identify(r *http.Request, w http.ResponseWriter, h *slog.Logger, op string)
 	mId, ok := r.Context().Value(UserIDKey).(string)
 
 	if !ok || mId == "" {
-		h.Warn(op, "UserID is missing or not a string", slog.String("user_id", mId))
+		h.WarnContext(r.Context(), op, "UserID is missing or not a string", slog.String("user_id", mId))
 		auth.DeleteCookie("access_token", w)
 		auth.DeleteCookie("refresh_token", w)
 		return uuid.Nil, response.Unauthorized("invalid account jwt"), errors.New("missing or invalid user ID")
@@ -88,7 +107,7 @@ func Identify(r *http.Request, w http.ResponseWriter, h *slog.Logger, op string)
 	userId, err := uuid.Parse(mId)
 
 	if err != nil {
-		h.Warn(op, "failed to parse id as a valid uuid from context", sl.Err(err))
+		h.WarnContext(r.Context(), op, "failed to parse id as a valid uuid from context", sl.Err(err))
 		auth.DeleteCookie("access_token", w)
 		auth.DeleteCookie("refresh_token", w)
 		return uuid.Nil, response.Unauthorized("invalid account jwt"), err
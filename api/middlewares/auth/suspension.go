@@ -0,0 +1,32 @@
+package authmiddleware
+
+import (
+	"context"
+	"time"
+)
+
+// Suspension describes an account that may not be used until Until.
+type Suspension struct {
+	Until  time.Time
+	Reason string
+}
+
+// SuspensionChecker returns the active suspension of a user, or nil when the
+// account is in good standing.
+type SuspensionChecker func(ctx context.Context, userID string) (*Suspension, error)
+
+var checkSuspension SuspensionChecker
+
+// SetSuspensionChecker installs the lookup used by the auth middlewares to
+// reject suspended accounts. Without it no suspension is enforced.
+func SetSuspensionChecker(checker SuspensionChecker) {
+	checkSuspension = checker
+}
+
+func suspensionOf(ctx context.Context, userID string) (*Suspension, error) {
+	if checkSuspension == nil {
+		return nil, nil
+	}
+
+	return checkSuspension(ctx, userID)
+}
@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"poster/internal/database"
+	"poster/internal/lib/contentfilter"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+)
+
+// Screen runs a new or edited post or comment through the content filter. On
+// rejection or failure the error response is already written.
+func Screen(w http.ResponseWriter, r *http.Request, log *slog.Logger, op string, filter *contentfilter.Pipeline, c contentfilter.Content) (contentfilter.Verdict, bool) {
+	verdict, err := filter.Check(r.Context(), c)
+
+	if err != nil {
+		log.ErrorContext(r.Context(), "Content filter failed", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to check content"))
+		return verdict, false
+	}
+
+	if verdict.Action == contentfilter.Reject {
+		log.InfoContext(r.Context(), "Content rejected", slog.String("op", op), slog.String("reason", verdict.Reason))
+		errD := response.ContentRejected(verdict.Reason)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return verdict, false
+	}
+
+	return verdict, true
+}
+
+// Hold hides an edited post or comment flagged by the content filter until a
+// moderator reviews it. Pass the queries of the transaction that saved the
+// edit. On failure the error response is already written.
+func Hold(w http.ResponseWriter, r *http.Request, log *slog.Logger, q *database.Queries, op, targetType string, targetId uuid.UUID, verdict contentfilter.Verdict) bool {
+	return hold(w, r, log, op, targetType, verdict, HoldForReview(r.Context(), q, targetType, targetId, verdict.Reason))
+}
+
+// HoldCreated files the review report for a new post or comment the content
+// filter flagged. The content must be inserted hidden with the queries q of
+// the same transaction. On failure the error response is already written.
+func HoldCreated(w http.ResponseWriter, r *http.Request, log *slog.Logger, q *database.Queries, op, targetType string, targetId uuid.UUID, verdict contentfilter.Verdict) bool {
+	return hold(w, r, log, op, targetType, verdict, ReportHeld(r.Context(), q, targetType, targetId, verdict.Reason))
+}
+
+func hold(w http.ResponseWriter, r *http.Request, log *slog.Logger, op, targetType string, verdict contentfilter.Verdict, err error) bool {
+	if err != nil {
+		log.ErrorContext(r.Context(), "Failed to hold content for review", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, targetType)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return false
+	}
+
+	log.InfoContext(r.Context(), "Content held for review", slog.String("op", op), slog.String("target_type", targetType), slog.String("reason", verdict.Reason))
+
+	return true
+}
@@ -0,0 +1,471 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/txn"
+	"time"
+)
+
+const (
+	reportLabel = "report"
+
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+
+	TargetPost    = "post"
+	TargetComment = "comment"
+	TargetUser    = "user"
+
+	StatusOpen      = "open"
+	StatusActioned  = "actioned"
+	StatusDismissed = "dismissed"
+
+	ActionDismiss = "dismiss"
+	ActionHide    = "hide"
+	ActionDelete  = "delete"
+)
+
+var (
+	errNotModerator      = errors.New("moderator role required")
+	errReportResolved    = errors.New("report is already resolved")
+	errTargetNotFound    = errors.New("reported content not found")
+	errUnknownTargetType = errors.New("unknown target type")
+)
+
+type Handler struct {
+	logger   *slog.Logger
+	txs      *txn.Runner
+	query    *database.Queries
+	validate *validator.Validate
+}
+
+// reportResponse keeps reporter_id a plain id. Reports filed by the content
+// filter have no reporter and leave it out.
+type reportResponse struct {
+	database.Report
+	ReporterID *uuid.UUID `json:"reporter_id,omitempty"`
+}
+
+type reportListItem struct {
+	reportResponse
+	ReporterUsername     *string `json:"reporter_username,omitempty"`
+	OpenReportsForTarget int64   `json:"open_reports_for_target"`
+}
+
+func toReportResponse(report database.Report) reportResponse {
+	res := reportResponse{Report: report}
+
+	if report.ReporterID.Valid {
+		res.ReporterID = &report.ReporterID.UUID
+	}
+
+	return res
+}
+
+type resolveRequest struct {
+	Action string `json:"action" validate:"required,oneof=dismiss hide delete"`
+	Note   string `json:"note" validate:"max=1000"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.With(authmiddleware.JWTAuthRequired).Post("/reports", handler.CreateReport)
+
+	r.Route("/moderation", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Get("/reports", handler.GetReports)
+		r.Post("/reports/{id}/resolve", handler.ResolveReport)
+		r.Get("/actions", handler.GetActions)
+	})
+
+	r.Route("/admin/users/{id}", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Post("/suspend", handler.SuspendUser)
+		r.Delete("/suspend", handler.UnsuspendUser)
+	})
+}
+
+func NewModerationHandler(log *slog.Logger, txs *txn.Runner, queries *database.Queries) *Handler {
+	return &Handler{
+		logger:   log,
+		txs:      txs,
+		query:    queries,
+		validate: validator.New(),
+	}
+}
+
+// IsModerator reports whether the user may act on other users' content.
+func IsModerator(ctx context.Context, q *database.Queries, userId uuid.UUID) (bool, error) {
+	role, err := q.GetUserRole(ctx, userId)
+
+	if err != nil {
+		return false, err
+	}
+
+	return role == RoleModerator || role == RoleAdmin, nil
+}
+
+// requireRole identifies the caller and checks their role is one of roles.
+// On failure the error response is already written.
+func (h *Handler) requireRole(w http.ResponseWriter, r *http.Request, op string, roles ...string) (uuid.UUID, bool) {
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, false
+	}
+
+	role, err := h.query.GetUserRole(r.Context(), userId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get user role", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "user")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, false
+	}
+
+	for _, allowed := range roles {
+		if role == allowed {
+			return userId, true
+		}
+	}
+
+	h.logger.WarnContext(r.Context(), "Moderation access denied", slog.String("op", op), slog.String("user_id", userId.String()))
+	json.WriteJSON(w, http.StatusForbidden, response.Forbidden(errNotModerator.Error()))
+	return uuid.Nil, false
+}
+
+func (h *Handler) GetReports(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.GetReports"
+
+	if _, ok := h.requireRole(w, r, op, RoleModerator, RoleAdmin); !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+
+	switch status {
+	case "":
+		status = StatusOpen
+	case StatusOpen, StatusActioned, StatusDismissed:
+	default:
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("unknown report status"))
+		return
+	}
+
+	targetType := r.URL.Query().Get("target_type")
+
+	if targetType != "" && targetType != TargetPost && targetType != TargetComment {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(errUnknownTargetType.Error()))
+		return
+	}
+
+	reports, err := h.query.GetReports(r.Context(), database.GetReportsParams{
+		Status:     status,
+		TargetType: sql.NullString{String: targetType, Valid: targetType != ""},
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get reports", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, reportLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	items := make([]reportListItem, 0, len(reports))
+
+	for _, row := range reports {
+		item := reportListItem{
+			reportResponse: toReportResponse(database.Report{
+				ID:         row.ID,
+				ReporterID: row.ReporterID,
+				TargetType: row.TargetType,
+				TargetID:   row.TargetID,
+				Reason:     row.Reason,
+				Details:    row.Details,
+				Status:     row.Status,
+				ResolvedBy: row.ResolvedBy,
+				ResolvedAt: row.ResolvedAt,
+				CreatedAt:  row.CreatedAt,
+			}),
+			OpenReportsForTarget: row.OpenReportsForTarget,
+		}
+
+		if row.ReporterUsername.Valid {
+			item.ReporterUsername = &row.ReporterUsername.String
+		}
+
+		items = append(items, item)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(items))
+}
+
+func (h *Handler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.ResolveReport"
+
+	moderatorId, ok := h.requireRole(w, r, op, RoleModerator, RoleAdmin)
+
+	if !ok {
+		return
+	}
+
+	idAlias := chi.URLParam(r, "id")
+
+	reportId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	var req resolveRequest
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if err = h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	report, err := h.resolve(r.Context(), moderatorId, reportId, req)
+
+	switch {
+	case errors.Is(err, errReportResolved):
+		json.WriteJSON(w, http.StatusConflict, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		})
+		return
+	case errors.Is(err, errTargetNotFound):
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound(err.Error()))
+		return
+	case err != nil:
+		h.logger.WarnContext(r.Context(), "Failed to resolve report", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, reportLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(toReportResponse(report), "Report resolved"))
+}
+
+func (h *Handler) GetActions(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.GetActions"
+
+	if _, ok := h.requireRole(w, r, op, RoleModerator, RoleAdmin); !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	targetId := uuid.NullUUID{}
+
+	if raw := r.URL.Query().Get("target_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+
+		if err != nil {
+			json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid target_id"))
+			return
+		}
+
+		targetId = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	actions, err := h.query.GetModerationActions(r.Context(), database.GetModerationActionsParams{
+		TargetID:   targetId,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get moderation actions", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "moderation action")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if len(actions) == 0 {
+		actions = []database.GetModerationActionsRow{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(actions))
+}
+
+// resolve applies the moderator decision, closes the report (and, when the
+// content is acted upon, every other open report on it) and writes the audit
+// entry in one transaction.
+func (h *Handler) resolve(ctx context.Context, moderatorId, reportId uuid.UUID, req resolveRequest) (database.Report, error) {
+	tx, q, err := h.txs.Begin(ctx)
+
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now()
+	moderator := uuid.NullUUID{UUID: moderatorId, Valid: true}
+
+	report, err := q.GetReport(ctx, reportId)
+
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	if report.Status != StatusOpen {
+		return database.Report{}, errReportResolved
+	}
+
+	status := StatusActioned
+
+	if req.Action == ActionDismiss {
+		status = StatusDismissed
+
+		if err := releaseHeld(ctx, q, report); err != nil {
+			return database.Report{}, err
+		}
+	} else if err := applyToTarget(ctx, q, req.Action, report, moderator, now); err != nil {
+		return database.Report{}, err
+	}
+
+	report, err = q.ResolveReport(ctx, database.ResolveReportParams{
+		Status:     status,
+		ResolvedBy: moderator,
+		ResolvedAt: sql.NullTime{Time: now, Valid: true},
+		ID:         report.ID,
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Report{}, errReportResolved
+	}
+
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	if status == StatusActioned {
+		_, err = q.ResolveTargetReports(ctx, database.ResolveTargetReportsParams{
+			Status:     StatusActioned,
+			ResolvedBy: moderator,
+			ResolvedAt: sql.NullTime{Time: now, Valid: true},
+			TargetType: report.TargetType,
+			TargetID:   report.TargetID,
+		})
+
+		if err != nil {
+			return database.Report{}, err
+		}
+	}
+
+	_, err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ID:          uuid.New(),
+		ModeratorID: moderator,
+		Action:      req.Action + "_" + report.TargetType,
+		TargetType:  report.TargetType,
+		TargetID:    report.TargetID,
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		Note:        req.Note,
+		CreatedAt:   now,
+	})
+
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	return report, tx.Commit()
+}
+
+// releaseHeld makes content held back by the content filter visible again
+// once a moderator dismisses the report on it.
+func releaseHeld(ctx context.Context, q *database.Queries, report database.Report) error {
+	var err error
+
+	switch report.TargetType {
+	case TargetPost:
+		_, err = q.ReleaseHeldPost(ctx, report.TargetID)
+	case TargetComment:
+		_, err = q.ReleaseHeldComment(ctx, report.TargetID)
+	}
+
+	return err
+}
+
+// applyToTarget hides or soft-deletes the reported post or comment. Content
+// that is already gone or hidden by a moderator yields errTargetNotFound;
+// content held by the content filter gets hidden for good.
+func applyToTarget(ctx context.Context, q *database.Queries, action string, report database.Report, moderator uuid.NullUUID, now time.Time) error {
+	var (
+		rows int64
+		err  error
+	)
+
+	at := sql.NullTime{Time: now, Valid: true}
+
+	switch {
+	case action == ActionHide && report.TargetType == TargetPost:
+		rows, err = q.HidePost(ctx, database.HidePostParams{ID: report.TargetID, HiddenAt: at, HiddenBy: moderator})
+	case action == ActionHide && report.TargetType == TargetComment:
+		rows, err = q.HideComment(ctx, database.HideCommentParams{ID: report.TargetID, HiddenAt: at, HiddenBy: moderator})
+	case action == ActionDelete && report.TargetType == TargetPost:
+		rows, err = q.DeletePost(ctx, database.DeletePostParams{ID: report.TargetID, DeletedAt: at, DeletedBy: moderator})
+	case action == ActionDelete && report.TargetType == TargetComment:
+		rows, err = q.DeleteCommentByID(ctx, database.DeleteCommentByIDParams{ID: report.TargetID, DeletedAt: at, DeletedBy: moderator})
+	default:
+		return errUnknownTargetType
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errTargetNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"poster/internal/database"
+	"poster/internal/lib/sql/sqltest"
+	"poster/internal/txn"
+	"testing"
+	"time"
+)
+
+func TestResolve_HeldContent(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+
+	db := sqltest.Open(t)
+	h := NewModerationHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), txn.NewRunner(db, nil), database.New(db))
+
+	author, moderator := uuid.New(), uuid.New()
+	now := time.Now()
+
+	for _, id := range []uuid.UUID{author, moderator} {
+		_, err := db.Exec(`INSERT INTO users (id, username, email, password_hash, created_at, updated_at)
+			VALUES ($1, $2, $3, '', $4, $4)`, id, id.String(), id.String()+"@example.com", now)
+		assert.NoError(err)
+	}
+
+	// hold creates a held post the way the posts handler does and returns the
+	// report the content filter filed.
+	hold := func(t *testing.T) (uuid.UUID, uuid.UUID) {
+		postId := uuid.New()
+
+		tx, q, err := h.txs.Begin(ctx)
+		assert.NoError(err)
+
+		_, err = q.CreatePost(ctx, database.CreatePostParams{
+			ID:        postId,
+			AuthorID:  author,
+			Title:     "title",
+			Content:   "content",
+			CreatedAt: now,
+			UpdatedAt: now,
+			HiddenAt:  sql.NullTime{Time: now, Valid: true},
+		})
+		assert.NoError(err)
+
+		assert.NoError(ReportHeld(ctx, q, TargetPost, postId, "too many links for a new account"))
+		assert.NoError(tx.Commit())
+
+		var reportId uuid.UUID
+		assert.NoError(db.QueryRow(`SELECT id FROM reports WHERE target_id = $1`, postId).Scan(&reportId))
+
+		return postId, reportId
+	}
+
+	hidden := func(postId uuid.UUID) (sql.NullTime, uuid.NullUUID) {
+		var (
+			at sql.NullTime
+			by uuid.NullUUID
+		)
+
+		assert.NoError(db.QueryRow(`SELECT hidden_at, hidden_by FROM posts WHERE id = $1`, postId).Scan(&at, &by))
+
+		return at, by
+	}
+
+	t.Run("hide confirms the hold", func(t *testing.T) {
+		postId, reportId := hold(t)
+
+		report, err := h.resolve(ctx, moderator, reportId, resolveRequest{Action: ActionHide})
+		assert.NoError(err)
+		assert.Equal(StatusActioned, report.Status)
+
+		at, by := hidden(postId)
+		assert.True(at.Valid)
+		assert.Equal(uuid.NullUUID{UUID: moderator, Valid: true}, by)
+	})
+
+	t.Run("dismiss releases the hold", func(t *testing.T) {
+		postId, reportId := hold(t)
+
+		report, err := h.resolve(ctx, moderator, reportId, resolveRequest{Action: ActionDismiss})
+		assert.NoError(err)
+		assert.Equal(StatusDismissed, report.Status)
+
+		at, _ := hidden(postId)
+		assert.False(at.Valid)
+	})
+
+	t.Run("content hidden by a moderator is not hidden again", func(t *testing.T) {
+		postId, reportId := hold(t)
+
+		_, err := h.query.HidePost(ctx, database.HidePostParams{
+			ID:       postId,
+			HiddenAt: sql.NullTime{Time: now, Valid: true},
+			HiddenBy: uuid.NullUUID{UUID: moderator, Valid: true},
+		})
+		assert.NoError(err)
+
+		_, err = h.resolve(ctx, moderator, reportId, resolveRequest{Action: ActionHide})
+		assert.ErrorIs(err, errTargetNotFound)
+	})
+}
@@ -0,0 +1,153 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+type reportRequest struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment"`
+	TargetID   string `json:"target_id" validate:"required,uuid"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence nudity misinformation other"`
+	Details    string `json:"details" validate:"max=2000"`
+}
+
+func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.CreateReport"
+
+	reporterId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	var req reportRequest
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if err = h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	targetId := uuid.MustParse(req.TargetID)
+
+	exists, err := h.targetExists(r.Context(), req.TargetType, targetId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to look up reported content", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, req.TargetType)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if !exists {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound(errTargetNotFound.Error()))
+		return
+	}
+
+	report, err := h.query.CreateReport(r.Context(), database.CreateReportParams{
+		ID:         uuid.New(),
+		ReporterID: uuid.NullUUID{UUID: reporterId, Valid: true},
+		TargetType: req.TargetType,
+		TargetID:   targetId,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		CreatedAt:  time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to create report", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, reportLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusCreated, response.OkWDataAMsg(toReportResponse(report), "Report submitted"))
+}
+
+// targetExists reports whether the post or comment a report points at exists.
+func (h *Handler) targetExists(ctx context.Context, targetType string, id uuid.UUID) (bool, error) {
+	var err error
+
+	switch targetType {
+	case TargetPost:
+		_, err = h.query.GetPostByID(ctx, id)
+	case TargetComment:
+		_, err = h.query.GetComment(ctx, id)
+	default:
+		return false, errUnknownTargetType
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// HoldForReview hides a post or comment flagged by the content filter and
+// files a report without a reporter so it shows up in the moderation queue.
+// Dismissing that report releases the content.
+func HoldForReview(ctx context.Context, q *database.Queries, targetType string, targetId uuid.UUID, reason string) error {
+	now := time.Now()
+	at := sql.NullTime{Time: now, Valid: true}
+
+	var err error
+
+	switch targetType {
+	case TargetPost:
+		_, err = q.HidePost(ctx, database.HidePostParams{ID: targetId, HiddenAt: at})
+	case TargetComment:
+		_, err = q.HideComment(ctx, database.HideCommentParams{ID: targetId, HiddenAt: at})
+	default:
+		return errUnknownTargetType
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ReportHeld(ctx, q, targetType, targetId, reason)
+}
+
+// ReportHeld files the report for a post or comment that was created already
+// hidden. Pass the queries of the transaction that created it, so the content
+// is never hidden without a report.
+func ReportHeld(ctx context.Context, q *database.Queries, targetType string, targetId uuid.UUID, reason string) error {
+	_, err := q.CreateReport(ctx, database.CreateReportParams{
+		ID:         uuid.New(),
+		TargetType: targetType,
+		TargetID:   targetId,
+		Reason:     "spam",
+		Details:    reason,
+		CreatedAt:  time.Now(),
+	})
+
+	return err
+}
@@ -0,0 +1,222 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+var (
+	errUserNotFound     = errors.New("user not found")
+	errUserNotSuspended = errors.New("user is not suspended")
+)
+
+type suspendRequest struct {
+	Until  time.Time `json:"until" validate:"required"`
+	Reason string    `json:"reason" validate:"required,max=500"`
+}
+
+type suspensionResponse struct {
+	UserID         uuid.UUID `json:"user_id"`
+	Reason         string    `json:"reason"`
+	SuspendedUntil time.Time `json:"suspended_until"`
+}
+
+// SuspensionChecker looks up active suspensions for the auth middlewares.
+func SuspensionChecker(q *database.Queries) authmiddleware.SuspensionChecker {
+	return func(ctx context.Context, userID string) (*authmiddleware.Suspension, error) {
+		id, err := uuid.Parse(userID)
+
+		if err != nil {
+			return nil, nil
+		}
+
+		s, err := q.GetUserSuspension(ctx, id)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !s.SuspendedUntil.Valid || !s.SuspendedUntil.Time.After(time.Now()) {
+			return nil, nil
+		}
+
+		return &authmiddleware.Suspension{Until: s.SuspendedUntil.Time, Reason: s.SuspensionReason}, nil
+	}
+}
+
+// targetUser parses the {id} param of the admin user routes.
+func (h *Handler) targetUser(w http.ResponseWriter, r *http.Request, op string) (uuid.UUID, bool) {
+	idAlias := chi.URLParam(r, "id")
+
+	userId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return uuid.Nil, false
+	}
+
+	return userId, true
+}
+
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.SuspendUser"
+
+	adminId, ok := h.requireRole(w, r, op, RoleAdmin)
+
+	if !ok {
+		return
+	}
+
+	userId, ok := h.targetUser(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	if userId == adminId {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("you cannot suspend yourself"))
+		return
+	}
+
+	var req suspendRequest
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	if !req.Until.After(time.Now()) {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("until must be in the future"))
+		return
+	}
+
+	err := h.setSuspension(r.Context(), adminId, userId, func(q *database.Queries) (int64, error) {
+		return q.SuspendUser(r.Context(), database.SuspendUserParams{
+			ID:               userId,
+			SuspendedUntil:   sql.NullTime{Time: req.Until, Valid: true},
+			SuspensionReason: req.Reason,
+		})
+	}, "suspend_user", req.Reason)
+
+	if errors.Is(err, errUserNotFound) {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound(err.Error()))
+		return
+	}
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to suspend user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "user")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(suspensionResponse{
+		UserID:         userId,
+		Reason:         req.Reason,
+		SuspendedUntil: req.Until,
+	}, "User suspended"))
+}
+
+func (h *Handler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	const op = "moderation.UnsuspendUser"
+
+	adminId, ok := h.requireRole(w, r, op, RoleAdmin)
+
+	if !ok {
+		return
+	}
+
+	userId, ok := h.targetUser(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	err := h.setSuspension(r.Context(), adminId, userId, func(q *database.Queries) (int64, error) {
+		return q.UnsuspendUser(r.Context(), userId)
+	}, "unsuspend_user", "")
+
+	if errors.Is(err, errUserNotFound) {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound(errUserNotSuspended.Error()))
+		return
+	}
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to unsuspend user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "user")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User unsuspended"))
+}
+
+// setSuspension runs update and records the audit entry in one transaction.
+// An update touching no rows yields errUserNotFound.
+func (h *Handler) setSuspension(ctx context.Context, adminId, userId uuid.UUID, update func(q *database.Queries) (int64, error), action, note string) error {
+	tx, q, err := h.txs.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := update(q)
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errUserNotFound
+	}
+
+	_, err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ID:          uuid.New(),
+		ModeratorID: uuid.NullUUID{UUID: adminId, Valid: true},
+		Action:      action,
+		TargetType:  TargetUser,
+		TargetID:    userId,
+		Note:        note,
+		CreatedAt:   time.Now(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
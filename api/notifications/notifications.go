@@ -0,0 +1,222 @@
+package notifications
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/lib/unsubscribe"
+	"poster/internal/notify"
+	"time"
+)
+
+const label = "notification"
+
+type Handler struct {
+	logger   *slog.Logger
+	query    *database.Queries
+	validate *validator.Validate
+	signer   *unsubscribe.Signer
+}
+
+type actor struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+type notification struct {
+	ID         uuid.UUID  `json:"id"`
+	Type       string     `json:"type"`
+	TargetID   uuid.UUID  `json:"target_id"`
+	Actor      actor      `json:"actor"`
+	ActorCount int64      `json:"actor_count"`
+	Message    string     `json:"message"`
+	Read       bool       `json:"read"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type notificationsResponse struct {
+	Notifications []notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+	pagination.Page
+}
+
+type markAllResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Route("/notifications", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Get("/", handler.GetNotifications)
+		r.Post("/read-all", handler.MarkAllRead)
+		r.Post("/{id}/read", handler.MarkRead)
+	})
+
+	r.Route("/account/notification-settings", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Get("/", handler.GetSettings)
+		r.Put("/", handler.UpdateSettings)
+	})
+
+	// Only POST unsubscribes: mail clients send one-click unsubscribes that
+	// way (RFC 8058). Following the link with GET, as people and link
+	// scanners do, just asks for confirmation.
+	r.Get("/unsubscribe", handler.ConfirmUnsubscribe)
+	r.Post("/unsubscribe", handler.Unsubscribe)
+}
+
+func NewNotificationsHandler(log *slog.Logger, db *database.Queries, signer *unsubscribe.Signer) *Handler {
+	return &Handler{
+		logger:   log,
+		query:    db,
+		validate: validator.New(),
+		signer:   signer,
+	}
+}
+
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.GetNotifications"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	rows, err := h.query.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     userId,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get notifications", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	unread, err := h.query.CountUnreadNotifications(r.Context(), userId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to count unread notifications", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := notificationsResponse{
+		Notifications: make([]notification, 0, len(rows)),
+		UnreadCount:   unread,
+		Page:          page,
+	}
+
+	for _, row := range rows {
+		n := notification{
+			ID:         row.ID,
+			Type:       row.Type,
+			TargetID:   row.TargetID,
+			Actor:      actor{ID: row.ActorID, Username: row.ActorUsername},
+			ActorCount: row.ActorCount,
+			Message:    notify.Summary(row.Type, row.ActorUsername, row.ActorCount),
+			Read:       row.ReadAt.Valid,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+		}
+
+		if row.ReadAt.Valid {
+			n.ReadAt = &row.ReadAt.Time
+		}
+
+		res.Notifications = append(res.Notifications, n)
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
+
+func (h *Handler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.MarkRead"
+
+	idAlias := chi.URLParam(r, "id")
+
+	notificationId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	rows, err := h.query.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ReadAt: time.Now(),
+		ID:     notificationId,
+		UserID: userId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to mark notification read", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if rows == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("notification not found"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("Notification marked as read"))
+}
+
+func (h *Handler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.MarkAllRead"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	marked, err := h.query.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		ReadAt: time.Now(),
+		UserID: userId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to mark notifications read", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(markAllResponse{Marked: marked}, "Notifications marked as read"))
+}
@@ -0,0 +1,209 @@
+package notifications
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/lib/unsubscribe"
+	"poster/internal/notify"
+	"time"
+)
+
+const settingsLabel = "notification settings"
+
+// settings holds how often each kind of notification is emailed: off,
+// immediate, daily or weekly.
+type settings struct {
+	Replies  string `json:"replies" validate:"required,oneof=off immediate daily weekly"`
+	Mentions string `json:"mentions" validate:"required,oneof=off immediate daily weekly"`
+	Follows  string `json:"follows" validate:"required,oneof=off immediate daily weekly"`
+}
+
+func settingsFrom(s database.NotificationSetting) settings {
+	return settings{Replies: s.Replies, Mentions: s.Mentions, Follows: s.Follows}
+}
+
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.GetSettings"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	current, err := h.query.GetNotificationSettings(r.Context(), userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		json.WriteJSON(w, http.StatusOK, response.OkWData(settings{
+			Replies:  notify.FrequencyOff,
+			Mentions: notify.FrequencyOff,
+			Follows:  notify.FrequencyOff,
+		}))
+		return
+	}
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get notification settings", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, settingsLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(settingsFrom(current)))
+}
+
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.UpdateSettings"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	var req settings
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	updated, err := h.query.UpsertNotificationSettings(r.Context(), database.UpsertNotificationSettingsParams{
+		UserID:    userId,
+		Replies:   req.Replies,
+		Mentions:  req.Mentions,
+		Follows:   req.Follows,
+		UpdatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to update notification settings", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, settingsLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(settingsFrom(updated), "Notification settings updated"))
+}
+
+type unsubscribePrompt struct {
+	Category string `json:"category"`
+}
+
+// ConfirmUnsubscribe checks the link from a notification email and tells the
+// client what a POST to it would turn off. It changes nothing, so mail
+// scanners prefetching the link do not unsubscribe anyone.
+func (h *Handler) ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.ConfirmUnsubscribe"
+
+	_, category, err := h.signer.Parse(r.URL.Query().Get("token"))
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid unsubscribe token", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid unsubscribe link"))
+		return
+	}
+
+	if !unsubscribable(category) {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid unsubscribe link"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(unsubscribePrompt{Category: category}, "Send a POST request to this link to unsubscribe"))
+}
+
+func unsubscribable(category string) bool {
+	switch category {
+	case unsubscribe.All, notify.CategoryReplies, notify.CategoryMentions, notify.CategoryFollows:
+		return true
+	}
+
+	return false
+}
+
+// Unsubscribe turns off the email category named by a signed token from a
+// notification email. It needs no login, so the link works from any device.
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	const op = "notifications.Unsubscribe"
+
+	userId, category, err := h.signer.Parse(r.URL.Query().Get("token"))
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid unsubscribe token", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid unsubscribe link"))
+		return
+	}
+
+	current, err := h.query.GetNotificationSettings(r.Context(), userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing was ever turned on, so there is nothing to turn off.
+		json.WriteJSON(w, http.StatusOK, response.OkWMsg("You have been unsubscribed"))
+		return
+	}
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get notification settings", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, settingsLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	next := settingsFrom(current)
+
+	switch category {
+	case unsubscribe.All:
+		next = settings{Replies: notify.FrequencyOff, Mentions: notify.FrequencyOff, Follows: notify.FrequencyOff}
+	case notify.CategoryReplies:
+		next.Replies = notify.FrequencyOff
+	case notify.CategoryMentions:
+		next.Mentions = notify.FrequencyOff
+	case notify.CategoryFollows:
+		next.Follows = notify.FrequencyOff
+	default:
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid unsubscribe link"))
+		return
+	}
+
+	_, err = h.query.UpsertNotificationSettings(r.Context(), database.UpsertNotificationSettingsParams{
+		UserID:    userId,
+		Replies:   next.Replies,
+		Mentions:  next.Mentions,
+		Follows:   next.Follows,
+		UpdatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to unsubscribe", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, settingsLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("You have been unsubscribed"))
+}
@@ -0,0 +1,210 @@
+package posts
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"strings"
+	"time"
+)
+
+const bookmarkLabel = "bookmark"
+
+type bookmarkRequest struct {
+	Collection string `json:"collection" validate:"max=50"`
+}
+
+type bookmarksResponse struct {
+	Posts []database.GetBookmarkedPostsRow `json:"posts"`
+	pagination.Page
+}
+
+// collectionName maps an empty or blank collection to the default, unnamed one.
+func collectionName(name string) sql.NullString {
+	name = strings.TrimSpace(name)
+	return sql.NullString{String: name, Valid: name != ""}
+}
+
+func (h *Handler) BookmarkPost(w http.ResponseWriter, r *http.Request) {
+	const op = "posts.BookmarkPost"
+
+	idAlias := chi.URLParam(r, "id")
+
+	postId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	var req bookmarkRequest
+
+	// The body is optional, a bare POST saves into the default collection.
+	if r.ContentLength != 0 {
+		if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+			h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, details.StatusCode, details)
+			return
+		}
+	}
+
+	if err = h.validate.Struct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
+			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
+			return
+		}
+
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("invalid input data"))
+		return
+	}
+
+	if _, err := h.query.GetPostByID(r.Context(), postId); err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	bookmark, err := h.query.AddBookmark(r.Context(), database.AddBookmarkParams{
+		ID:         uuid.New(),
+		UserID:     userId,
+		PostID:     postId,
+		Collection: collectionName(req.Collection),
+		CreatedAt:  time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to bookmark post", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, bookmarkLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(bookmark, "Post bookmarked"))
+}
+
+func (h *Handler) UnbookmarkPost(w http.ResponseWriter, r *http.Request) {
+	const op = "posts.UnbookmarkPost"
+
+	idAlias := chi.URLParam(r, "id")
+
+	postId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	deleted, err := h.query.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID: userId,
+		PostID: postId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to delete bookmark", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, bookmarkLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if deleted == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("bookmark not found"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("Bookmark removed"))
+}
+
+func (h *Handler) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	const op = "posts.GetBookmarks"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	posts, err := h.query.GetBookmarkedPosts(r.Context(), database.GetBookmarkedPostsParams{
+		UserID:     userId,
+		Collection: collectionName(r.URL.Query().Get("collection")),
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get bookmarks", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, bookmarkLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if len(posts) == 0 {
+		posts = []database.GetBookmarkedPostsRow{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(bookmarksResponse{Posts: posts, Page: page}))
+}
+
+func (h *Handler) GetBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	const op = "posts.GetBookmarkCollections"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	collections, err := h.query.GetBookmarkCollections(r.Context(), userId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get bookmark collections", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, bookmarkLabel)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if len(collections) == 0 {
+		collections = []database.GetBookmarkCollectionsRow{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(collections))
+}
@@ -1,6 +1,8 @@
 package posts
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
@@ -8,25 +10,45 @@ import (
 	"log/slog"
 	"net/http"
 	authmiddleware "poster/api/middlewares/auth"
+	"poster/api/moderation"
 	"poster/internal/database"
+	"poster/internal/lib/contentfilter"
+	"poster/internal/lib/hashtags"
 	"poster/internal/lib/http/json"
 	"poster/internal/lib/http/response"
 	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/markdown"
 	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/mentions"
+	"poster/internal/metrics"
+	"poster/internal/txn"
 	"time"
 )
 
 const label = "post"
 
 type Handler struct {
-	logger   *slog.Logger
-	query    *database.Queries
-	validate *validator.Validate
+	logger    *slog.Logger
+	query     *database.Queries
+	txs       *txn.Runner
+	validate  *validator.Validate
+	retention time.Duration
+	filter    *contentfilter.Pipeline
+	mentioner *mentions.Mentioner
 }
 
 type postRequest struct {
-	Title   string `json:"title,required"`
-	Content string `json:"content,required"`
+	Title         string   `json:"title,required"`
+	Content       string   `json:"content,required"`
+	Tags          []string `json:"tags" validate:"omitempty,max=10,dive,required,max=50"`
+	AttachmentIDs []string `json:"attachment_ids" validate:"omitempty,max=10,dive,uuid"`
+}
+
+type postResponse struct {
+	database.Post
+	Tags          []string        `json:"tags"`
+	AttachmentIDs []uuid.UUID     `json:"attachment_ids"`
+	Mentions      []mentions.Span `json:"mentions"`
 }
 
 func RegisterRoutes(r chi.Router, handler *Handler) {
@@ -35,16 +57,30 @@ func RegisterRoutes(r chi.Router, handler *Handler) {
 		r.With(authmiddleware.JWTAuthRequired).Post("/", handler.CreatePost)
 		r.With(authmiddleware.JWTAuthRequired).Delete("/{id}", handler.DeletePost)
 		r.With(authmiddleware.JWTAuthRequired).Put("/{id}", handler.UpdatePost)
+		r.With(authmiddleware.JWTAuthRequired).Post("/{id}/restore", handler.RestorePost)
+
+		r.With(authmiddleware.JWTAuthRequired).Post("/{id}/bookmark", handler.BookmarkPost)
+		r.With(authmiddleware.JWTAuthRequired).Delete("/{id}/bookmark", handler.UnbookmarkPost)
+	})
+
+	r.Route("/account/bookmarks", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Get("/", handler.GetBookmarks)
+		r.Get("/collections", handler.GetBookmarkCollections)
 	})
 
 	r.With(authmiddleware.JWTAuthNotRequired).Get("/posts", handler.GetPosts)
 }
 
-func NewPostsHandler(log *slog.Logger, db *database.Queries) *Handler {
+func NewPostsHandler(log *slog.Logger, txs *txn.Runner, db *database.Queries, retention time.Duration, filter *contentfilter.Pipeline, mentioner *mentions.Mentioner) *Handler {
 	return &Handler{
-		logger:   log,
-		query:    db,
-		validate: validator.New(),
+		logger:    log,
+		query:     db,
+		txs:       txs,
+		retention: retention,
+		filter:    filter,
+		mentioner: mentioner,
+		validate:  validator.New(),
 	}
 }
 
@@ -57,27 +93,28 @@ func (h *Handler) GetPost(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(idAlias)
 
 	if err != nil {
-		h.logger.Warn(op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
 		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
 		return
 	}
 
-	post, err := h.query.GetPost(r.Context(), id)
-
-	if err != nil {
-		errD := sqlhelpers.GetDBError(err, label)
-		h.logger.Warn(op, "failed to get post", sl.Err(err))
-		json.WriteJSON(w, errD.StatusCode, errD)
-		return
-	}
-
 	userId := uuid.NullUUID{Valid: false}
 
 	if possibleId, _, err := authmiddleware.Identify(r, w, h.logger, op); err == nil {
 		userId = uuid.NullUUID{UUID: possibleId, Valid: true}
 	}
 
-	h.logger.Debug("user id", userId.UUID)
+	post, err := h.query.GetPost(r.Context(), database.GetPostParams{
+		PostID: id,
+		UserID: userId.UUID,
+	})
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		h.logger.WarnContext(r.Context(), op, "failed to get post", sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
 
 	commentsForPost, err := h.query.GetCommentsForPost(r.Context(), database.GetCommentsForPostParams{
 		PostID: post.ID,
@@ -86,7 +123,7 @@ func (h *Handler) GetPost(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		errD := sqlhelpers.GetDBError(err, "post comments")
-		h.logger.Warn(op, "failed to get post comments", sl.Err(err))
+		h.logger.WarnContext(r.Context(), op, "failed to get post comments", sl.Err(err))
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
@@ -119,7 +156,7 @@ func (h *Handler) GetPosts(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		errD := sqlhelpers.GetDBError(err, label)
-		h.logger.Warn(op, "failed to get post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), op, "failed to get post", sl.Err(err))
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
@@ -140,7 +177,7 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	postId, err := uuid.Parse(idAlias)
 
 	if err != nil {
-		h.logger.Warn(op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
 		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
 		return
 	}
@@ -152,10 +189,10 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := h.query.GetPost(r.Context(), postId)
+	post, err := h.query.GetPostByID(r.Context(), postId)
 
 	if err != nil {
-		h.logger.Warn("Failed to get post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to get post", sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -166,10 +203,14 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.query.DeletePost(r.Context(), post.ID)
+	_, err = h.query.DeletePost(r.Context(), database.DeletePostParams{
+		ID:        post.ID,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		DeletedBy: uuid.NullUUID{UUID: authorId, Valid: true},
+	})
 
 	if err != nil {
-		h.logger.Warn("Failed to delete post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to delete post", sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -192,7 +233,7 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -200,44 +241,107 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	if err = h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
 
-	post, err := h.query.CreatePost(r.Context(), database.CreatePostParams{
-		ID:        uuid.New(),
-		AuthorID:  authorId,
-		Title:     req.Title,
-		Content:   req.Content,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	attachmentIDs := uniqueIDs(req.AttachmentIDs)
+
+	if errD, err := h.checkAttachments(r.Context(), authorId, uuid.NullUUID{}, attachmentIDs); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid attachments", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	verdict, ok := moderation.Screen(w, r, h.logger, op, h.filter, contentfilter.Content{AuthorID: authorId, Text: req.Title + "\n" + req.Content})
+
+	if !ok {
+		return
+	}
+
+	tx, q, err := h.txs.Begin(r.Context())
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to begin transaction", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to create post"))
+		return
+	}
+
+	defer tx.Rollback()
+
+	post, err := q.CreatePost(r.Context(), database.CreatePostParams{
+		ID:          uuid.New(),
+		AuthorID:    authorId,
+		Title:       req.Title,
+		Content:     req.Content,
+		ContentHtml: markdown.Render(req.Content),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		HiddenAt:    sql.NullTime{Time: time.Now(), Valid: verdict.Action == contentfilter.Hold},
 	})
 
 	if err != nil {
-		h.logger.Warn("Failed to create post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to create post", sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	tags, ok := h.writePostTagsAndAttachments(w, r, q, op, authorId, post.ID, hashtags.Merge(req.Tags, req.Content), attachmentIDs)
+
+	if !ok {
+		return
+	}
+
+	if verdict.Action == contentfilter.Hold && !moderation.HoldCreated(w, r, h.logger, q, op, moderation.TargetPost, post.ID, verdict) {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to commit post", slog.String("op", op), sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
 	}
 
-	json.WriteJSON(w, http.StatusCreated, response.OkWDataAMsg(post, "Post created successfully"))
+	mentioned, err := h.mentioner.SavePost(r.Context(), post.ID, req.Content)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to save mentions", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "mention")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := postResponse{Post: post, Tags: tags, AttachmentIDs: attachmentIDs, Mentions: mentioned.Spans}
+
+	if verdict.Action == contentfilter.Hold {
+		metrics.PostsCreated.WithLabelValues("held").Inc()
+		json.WriteJSON(w, http.StatusAccepted, response.OkWDataAMsg(res, "Post held for review"))
+		return
+	}
+
+	metrics.PostsCreated.WithLabelValues("published").Inc()
+	h.mentioner.Notify(r.Context(), authorId, post.ID, mentioned.Added)
+
+	json.WriteJSON(w, http.StatusCreated, response.OkWDataAMsg(res, "Post created successfully"))
 }
 
 func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
-	const op = "posts.CreatePost"
+	const op = "posts.UpdatePost"
 
 	idAlias := chi.URLParam(r, "id")
 
 	postId, err := uuid.Parse(idAlias)
 
 	if err != nil {
-		h.logger.Warn(op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
 		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
 		return
 	}
@@ -252,7 +356,7 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
-		h.logger.Warn("Invalid JSON body", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
 		json.WriteJSON(w, details.StatusCode, details)
 		return
 	}
@@ -260,20 +364,20 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	if err = h.validate.Struct(req); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			h.logger.Warn("Validation failed", slog.String("op", op), sl.Err(err))
+			h.logger.WarnContext(r.Context(), "Validation failed", slog.String("op", op), sl.Err(err))
 			json.WriteJSON(w, http.StatusBadRequest, response.InvalidInput(validationErrors))
 			return
 		}
 
-		h.logger.Warn("Invalid input data", slog.String("op", op), sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Invalid input data", slog.String("op", op), sl.Err(err))
 		response.BadRequest("invalid input data")
 		return
 	}
 
-	post, err := h.query.GetPost(r.Context(), postId)
+	post, err := h.query.GetPostByID(r.Context(), postId)
 
 	if err != nil {
-		h.logger.Warn("Failed to get post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to get post", sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, errD.StatusCode, errD)
 		return
@@ -284,19 +388,200 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedP, err := h.query.UpdatePost(r.Context(), database.UpdatePostParams{
-		ID:        uuid.New(),
-		Title:     req.Title,
-		Content:   req.Content,
-		UpdatedAt: time.Now(),
+	attachmentIDs := uniqueIDs(req.AttachmentIDs)
+
+	if errD, err := h.checkAttachments(r.Context(), authorId, uuid.NullUUID{UUID: post.ID, Valid: true}, attachmentIDs); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid attachments", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	verdict, ok := moderation.Screen(w, r, h.logger, op, h.filter, contentfilter.Content{
+		AuthorID:  authorId,
+		ExcludeID: post.ID,
+		Text:      req.Title + "\n" + req.Content,
 	})
 
+	if !ok {
+		return
+	}
+
+	tx, q, err := h.txs.Begin(r.Context())
+
 	if err != nil {
-		h.logger.Warn("Failed to update post", sl.Err(err))
+		h.logger.WarnContext(r.Context(), "Failed to begin transaction", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("Failed to update post"))
+		return
+	}
+
+	defer tx.Rollback()
+
+	updatedP, err := q.UpdatePost(r.Context(), database.UpdatePostParams{
+		ID:          post.ID,
+		Title:       req.Title,
+		Content:     req.Content,
+		ContentHtml: markdown.Render(req.Content),
+		UpdatedAt:   time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to update post", sl.Err(err))
 		errD := sqlhelpers.GetDBError(err, label)
 		json.WriteJSON(w, http.StatusInternalServerError, errD)
 		return
 	}
 
-	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(updatedP, "Post updated successfully"))
+	tags, ok := h.writePostTagsAndAttachments(w, r, q, op, authorId, updatedP.ID, hashtags.Merge(req.Tags, req.Content), attachmentIDs)
+
+	if !ok {
+		return
+	}
+
+	if verdict.Action == contentfilter.Hold && !moderation.Hold(w, r, h.logger, q, op, moderation.TargetPost, updatedP.ID, verdict) {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to commit post", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	mentioned, err := h.mentioner.SavePost(r.Context(), updatedP.ID, req.Content)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to save mentions", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "mention")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := postResponse{Post: updatedP, Tags: tags, AttachmentIDs: attachmentIDs, Mentions: mentioned.Spans}
+
+	if verdict.Action == contentfilter.Hold {
+		json.WriteJSON(w, http.StatusAccepted, response.OkWDataAMsg(res, "Post held for review"))
+		return
+	}
+
+	h.mentioner.Notify(r.Context(), authorId, updatedP.ID, mentioned.Added)
+
+	json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(res, "Post updated successfully"))
+}
+
+// writePostTagsAndAttachments sets the tags and attachments of a post with q,
+// the queries of the transaction that wrote the post. On failure the error
+// response is already written.
+func (h *Handler) writePostTagsAndAttachments(w http.ResponseWriter, r *http.Request, q *database.Queries, op string, authorId, postID uuid.UUID, names []string, attachmentIDs []uuid.UUID) ([]string, bool) {
+	tags, err := setPostTags(r.Context(), q, postID, names)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to set post tags", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "tag")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return nil, false
+	}
+
+	if err = linkAttachments(r.Context(), q, authorId, postID, attachmentIDs); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to link attachments", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "attachment")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return nil, false
+	}
+
+	return tags, true
+}
+
+// setPostTags replaces the tags of a post with names, creating missing tags.
+func setPostTags(ctx context.Context, q *database.Queries, postID uuid.UUID, names []string) ([]string, error) {
+	if err := q.DeletePostTags(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		tag, err := q.UpsertTag(ctx, database.UpsertTagParams{
+			ID:        uuid.New(),
+			Name:      name,
+			CreatedAt: time.Now(),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = q.AddPostTag(ctx, database.AddPostTagParams{
+			PostID: postID,
+			TagID:  tag.ID,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return names, nil
+}
+
+// checkAttachments makes sure every attachment belongs to the author and is
+// either free or already linked to postID.
+func (h *Handler) checkAttachments(ctx context.Context, ownerID uuid.UUID, postID uuid.NullUUID, ids []uuid.UUID) (response.ErrorResp, error) {
+	if len(ids) == 0 {
+		return response.ErrorResp{}, nil
+	}
+
+	count, err := h.query.CountAttachableAttachments(ctx, database.CountAttachableAttachmentsParams{
+		Ids:     ids,
+		OwnerID: ownerID,
+		PostID:  postID,
+	})
+
+	if err != nil {
+		return sqlhelpers.GetDBError(err, "attachment"), err
+	}
+
+	if count != int64(len(ids)) {
+		return response.BadRequest("unknown or already used attachment"), errors.New("attachment is not available")
+	}
+
+	return response.ErrorResp{}, nil
+}
+
+// linkAttachments makes ids the exact set of attachments of the post.
+func linkAttachments(ctx context.Context, q *database.Queries, ownerID uuid.UUID, postID uuid.UUID, ids []uuid.UUID) error {
+	err := q.DetachFromPost(ctx, database.DetachFromPostParams{
+		PostID:  uuid.NullUUID{UUID: postID, Valid: true},
+		KeepIds: ids,
+	})
+
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	_, err = q.AttachToPost(ctx, database.AttachToPostParams{
+		PostID:  uuid.NullUUID{UUID: postID, Valid: true},
+		Ids:     ids,
+		OwnerID: ownerID,
+	})
+
+	return err
+}
+
+// uniqueIDs parses already validated uuids dropping duplicates.
+func uniqueIDs(raw []string) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := make(map[uuid.UUID]struct{}, len(raw))
+
+	for _, r := range raw {
+		id, err := uuid.Parse(r)
+		if err != nil {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	return ids
 }
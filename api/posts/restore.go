@@ -0,0 +1,112 @@
+package posts
+
+import (
+	"database/sql"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/api/moderation"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+// RestorePost brings back a soft-deleted or hidden post. Authors can undo
+// their own deletion, moderators can also restore hidden posts and posts
+// removed by other moderators. Either way only within the retention window.
+func (h *Handler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	const op = "posts.RestorePost"
+
+	idAlias := chi.URLParam(r, "id")
+
+	postId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	post, err := h.query.GetPostIncludingDeleted(r.Context(), postId)
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if !post.DeletedAt.Valid && !post.HiddenAt.Valid {
+		json.WriteJSON(w, http.StatusConflict, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusConflict,
+			Message:    "post is not deleted",
+		})
+		return
+	}
+
+	isModerator, err := moderation.IsModerator(r.Context(), h.query, userId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get user role", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, "user")
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	selfDeleted := post.AuthorID == userId && post.DeletedBy.UUID == userId && !post.HiddenAt.Valid
+
+	if !isModerator && !selfDeleted {
+		json.WriteJSON(w, http.StatusForbidden, response.Forbidden("You cannot restore this post"))
+		return
+	}
+
+	cutoff := time.Now().Add(-h.retention)
+
+	if post.DeletedAt.Valid && post.DeletedAt.Time.Before(cutoff) {
+		json.WriteJSON(w, http.StatusGone, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusGone,
+			Message:    "restore window has expired",
+		})
+		return
+	}
+
+	if _, err = h.query.RestorePost(r.Context(), database.RestorePostParams{
+		ID:           post.ID,
+		DeletedAfter: sql.NullTime{Time: cutoff, Valid: true},
+	}); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to restore post", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if !selfDeleted {
+		_, err = h.query.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ID:          uuid.New(),
+			ModeratorID: uuid.NullUUID{UUID: userId, Valid: true},
+			Action:      "restore_" + moderation.TargetPost,
+			TargetType:  moderation.TargetPost,
+			TargetID:    post.ID,
+			CreatedAt:   time.Now(),
+		})
+
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to record moderation action", slog.String("op", op), sl.Err(err))
+		}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("Post restored"))
+}
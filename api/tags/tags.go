@@ -0,0 +1,121 @@
+package tags
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/hashtags"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"strconv"
+)
+
+const (
+	label              = "tag"
+	defaultPopularSize = 20
+	maxPopularSize     = 100
+)
+
+type Handler struct {
+	logger *slog.Logger
+	query  *database.Queries
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Route("/tags", func(r chi.Router) {
+		r.Get("/popular", handler.GetPopularTags)
+		r.With(authmiddleware.JWTAuthNotRequired).Get("/{name}/posts", handler.GetTagPosts)
+	})
+}
+
+func NewTagsHandler(log *slog.Logger, db *database.Queries) *Handler {
+	return &Handler{
+		logger: log,
+		query:  db,
+	}
+}
+
+func (h *Handler) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	const op = "tags.GetTagPosts"
+
+	name, ok := hashtags.Normalize(chi.URLParam(r, "name"))
+
+	if !ok {
+		h.logger.WarnContext(r.Context(), "Invalid tag name", slog.String("op", op), slog.String("name", chi.URLParam(r, "name")))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid tag name"))
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	userId := uuid.NullUUID{Valid: false}
+
+	if possibleId, _, err := authmiddleware.Identify(r, w, h.logger, op); err == nil {
+		userId = uuid.NullUUID{UUID: possibleId, Valid: true}
+	}
+
+	posts, err := h.query.GetPostsByTag(r.Context(), database.GetPostsByTagParams{
+		TagName:    name,
+		UserID:     userId.UUID,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		h.logger.WarnContext(r.Context(), "Failed to get tag posts", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if len(posts) == 0 {
+		posts = []database.GetPostsByTagRow{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(posts))
+}
+
+func (h *Handler) GetPopularTags(w http.ResponseWriter, r *http.Request) {
+	const op = "tags.GetPopularTags"
+
+	limit := defaultPopularSize
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+
+		if err != nil || parsed <= 0 {
+			h.logger.WarnContext(r.Context(), "Invalid limit", slog.String("op", op), slog.String("limit", raw))
+			json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(pagination.ErrInvalidLimit.Error()))
+			return
+		}
+
+		limit = min(parsed, maxPopularSize)
+	}
+
+	tags, err := h.query.GetPopularTags(r.Context(), int32(limit))
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		h.logger.WarnContext(r.Context(), "Failed to get popular tags", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if len(tags) == 0 {
+		tags = []database.GetPopularTagsRow{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(tags))
+}
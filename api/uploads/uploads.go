@@ -0,0 +1,428 @@
+package uploads
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/lib/storage"
+	"poster/internal/thumbnails"
+	"strings"
+	"time"
+)
+
+const (
+	label             = "attachment"
+	formField         = "file"
+	multipartOverhead = 1 << 20
+	maxFileNameLength = 255
+)
+
+type Options struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+type Handler struct {
+	logger  *slog.Logger
+	query   *database.Queries
+	storage storage.Storage
+	maxSize int64
+	allowed map[string]bool
+}
+
+type attachmentResponse struct {
+	ID              uuid.UUID `json:"id"`
+	URL             string    `json:"url"`
+	FileName        string    `json:"file_name"`
+	MimeType        string    `json:"mime_type"`
+	SizeBytes       int64     `json:"size_bytes"`
+	ThumbnailStatus string    `json:"thumbnail_status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Route("/uploads", func(r chi.Router) {
+		r.With(authmiddleware.JWTAuthRequired).Post("/", handler.Upload)
+		r.Get("/{id}", handler.GetUpload)
+		r.Get("/{id}/thumbnails/{name}", handler.GetThumbnail)
+	})
+}
+
+func NewUploadsHandler(log *slog.Logger, db *database.Queries, store storage.Storage, opts Options) *Handler {
+	allowed := make(map[string]bool, len(opts.AllowedTypes))
+	for _, t := range opts.AllowedTypes {
+		allowed[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+
+	return &Handler{
+		logger:  log,
+		query:   db,
+		storage: store,
+		maxSize: opts.MaxSize,
+		allowed: allowed,
+	}
+}
+
+func toResponse(a database.Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:              a.ID,
+		URL:             "/uploads/" + a.ID.String(),
+		FileName:        a.FileName,
+		MimeType:        a.MimeType,
+		SizeBytes:       a.SizeBytes,
+		ThumbnailStatus: a.ThumbnailStatus,
+		CreatedAt:       a.CreatedAt,
+	}
+}
+
+func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
+	const op = "uploads.Upload"
+
+	ownerId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+multipartOverhead)
+
+	reader, err := r.MultipartReader()
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid multipart request", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("request must be multipart/form-data"))
+		return
+	}
+
+	var tmp *os.File
+	var fileName string
+
+	for {
+		part, err := reader.NextPart()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			h.writeReadError(w, r, op, err)
+			return
+		}
+
+		if part.FormName() != formField {
+			continue
+		}
+
+		fileName = cleanFileName(part.FileName())
+		tmp, err = h.spool(part)
+
+		if err != nil {
+			h.writeReadError(w, r, op, err)
+			return
+		}
+
+		break
+	}
+
+	if tmp == nil {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(fmt.Sprintf("missing %q file field", formField)))
+		return
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	info, err := tmp.Stat()
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to stat upload", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError(""))
+		return
+	}
+
+	size := info.Size()
+
+	if size > h.maxSize {
+		json.WriteJSON(w, http.StatusRequestEntityTooLarge, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("file must not be larger than %d bytes", h.maxSize),
+		})
+		return
+	}
+
+	if size == 0 {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("file must not be empty"))
+		return
+	}
+
+	mtype, err := h.detect(tmp)
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to detect mime type", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError(""))
+		return
+	}
+
+	mimeType := strings.ToLower(strings.Split(mtype.String(), ";")[0])
+
+	if !h.allowed[mimeType] {
+		h.logger.WarnContext(r.Context(), "Rejected upload type", slog.String("op", op), slog.String("mime", mimeType))
+		json.WriteJSON(w, http.StatusUnsupportedMediaType, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    fmt.Sprintf("file type %s is not allowed", mimeType),
+		})
+		return
+	}
+
+	hash, err := fileHash(tmp)
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to hash upload", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError(""))
+		return
+	}
+
+	existing, err := h.query.GetUnattachedAttachmentByHash(r.Context(), database.GetUnattachedAttachmentByHashParams{
+		OwnerID: ownerId,
+		Sha256:  hash,
+	})
+
+	if err == nil {
+		json.WriteJSON(w, http.StatusOK, response.OkWDataAMsg(toResponse(existing), "File already uploaded"))
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		errD = sqlhelpers.GetDBError(err, label)
+		h.logger.ErrorContext(r.Context(), "Failed to look up attachment", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	key := storageKey(hash, mtype.Extension())
+
+	if err = h.store(r, tmp, key, size, mimeType); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to store upload", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError("failed to store file"))
+		return
+	}
+
+	attachment, err := h.query.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:              uuid.New(),
+		OwnerID:         ownerId,
+		StorageKey:      key,
+		FileName:        fileName,
+		MimeType:        mimeType,
+		SizeBytes:       size,
+		Sha256:          hash,
+		ThumbnailStatus: thumbnails.StatusFor(mimeType),
+		CreatedAt:       time.Now(),
+	})
+
+	if err != nil {
+		errD = sqlhelpers.GetDBError(err, label)
+		h.logger.ErrorContext(r.Context(), "Failed to create attachment", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusCreated, response.OkWDataAMsg(toResponse(attachment), "File uploaded successfully"))
+}
+
+func (h *Handler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	const op = "uploads.GetUpload"
+
+	idAlias := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid attachment id", slog.String("op", op), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	attachment, err := h.query.GetAttachment(r.Context(), id)
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		h.logger.WarnContext(r.Context(), "Failed to get attachment", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		disposition = "inline"
+	}
+
+	h.serveBlob(w, r, op, attachment.StorageKey, attachment.MimeType, fmt.Sprintf("%s; filename=%q", disposition, attachment.FileName))
+}
+
+func (h *Handler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	const op = "uploads.GetThumbnail"
+
+	idAlias := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid attachment id", slog.String("op", op), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return
+	}
+
+	thumbnail, err := h.query.GetThumbnail(r.Context(), database.GetThumbnailParams{
+		AttachmentID: id,
+		Name:         chi.URLParam(r, "name"),
+	})
+
+	if err != nil {
+		errD := sqlhelpers.GetDBError(err, "thumbnail")
+		h.logger.WarnContext(r.Context(), "Failed to get thumbnail", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	h.serveBlob(w, r, op, thumbnail.StorageKey, thumbnail.MimeType, "inline")
+}
+
+// serveBlob streams a stored object. The length is not taken from the
+// database because the thumbnail worker may rewrite originals.
+func (h *Handler) serveBlob(w http.ResponseWriter, r *http.Request, op, key, mimeType, disposition string) {
+	rc, err := h.storage.Get(r.Context(), key)
+
+	if errors.Is(err, storage.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "Blob is missing", slog.String("op", op), slog.String("key", key))
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("attachment not found"))
+		return
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to read blob", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusInternalServerError, response.InternalServerError(""))
+		return
+	}
+
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	if _, err = io.Copy(w, rc); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to stream blob", slog.String("op", op), sl.Err(err))
+	}
+}
+
+// spool copies the part into a temporary file. At most maxSize+1 bytes are
+// read so oversized files can be detected without buffering them whole.
+func (h *Handler) spool(part io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "poster-upload-*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(tmp, io.LimitReader(part, h.maxSize+1)); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tmp, nil
+}
+
+func (h *Handler) detect(f *os.File) (*mimetype.MIME, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return mimetype.DetectReader(f)
+}
+
+func (h *Handler) store(r *http.Request, f *os.File, key string, size int64, mimeType string) error {
+	exists, err := h.storage.Exists(r.Context(), key)
+
+	if err != nil || exists {
+		return err
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return h.storage.Put(r.Context(), key, f, size, mimeType)
+}
+
+func (h *Handler) writeReadError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	var maxBytesError *http.MaxBytesError
+
+	if errors.As(err, &maxBytesError) {
+		json.WriteJSON(w, http.StatusRequestEntityTooLarge, response.ErrorResp{
+			Status:     response.StatusError,
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("file must not be larger than %d bytes", h.maxSize),
+		})
+		return
+	}
+
+	h.logger.WarnContext(r.Context(), "Failed to read upload", slog.String("op", op), sl.Err(err))
+	json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("failed to read uploaded file"))
+}
+
+func fileHash(f *os.File) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	sum := sha256.New()
+
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// storageKey derives the key from the content hash, so identical files are
+// stored once no matter who uploads them.
+func storageKey(hash, ext string) string {
+	return hash[:2] + "/" + hash + ext
+}
+
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+
+	return name
+}
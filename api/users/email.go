@@ -0,0 +1,111 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+// emailStatus tells the user whether mail to their address is delivered.
+// Deliverable is false once the address bounced or they reported our mail
+// as spam.
+type emailStatus struct {
+	Email       string       `json:"email"`
+	Verified    bool         `json:"verified"`
+	Deliverable bool         `json:"deliverable"`
+	Suppression *suppression `json:"suppression,omitempty"`
+}
+
+type suppression struct {
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail"`
+	Since  time.Time `json:"since"`
+}
+
+func (h *Handler) GetEmailStatus(w http.ResponseWriter, r *http.Request) {
+	const op = "users.GetEmailStatus"
+
+	user, ok := h.currentUser(w, r, op)
+	if !ok {
+		return
+	}
+
+	status := emailStatus{
+		Email:       user.Email,
+		Verified:    user.IsVerified.Valid && user.IsVerified.Bool,
+		Deliverable: true,
+	}
+
+	s, err := h.query.GetEmailSuppression(r.Context(), user.Email)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.WarnContext(r.Context(), "Failed to get email suppression", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if err == nil {
+		status.Deliverable = false
+		status.Suppression = &suppression{Reason: s.Reason, Detail: s.Detail, Since: s.CreatedAt}
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(status))
+}
+
+// ResumeEmail lifts the suppression of the user's address, after they fixed
+// their mailbox or want our mail again after reporting it.
+func (h *Handler) ResumeEmail(w http.ResponseWriter, r *http.Request) {
+	const op = "users.ResumeEmail"
+
+	user, ok := h.currentUser(w, r, op)
+	if !ok {
+		return
+	}
+
+	rows, err := h.query.DeleteEmailSuppression(r.Context(), user.Email)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to delete email suppression", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if rows == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("email is not suppressed"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("Email delivery resumed"))
+}
+
+// currentUser loads the caller. On failure the error response is already
+// written.
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request, op string) (database.User, bool) {
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return database.User{}, false
+	}
+
+	user, err := h.query.GetUserByUUID(r.Context(), userId)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return database.User{}, false
+	}
+
+	return user, true
+}
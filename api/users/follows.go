@@ -0,0 +1,178 @@
+package users
+
+import (
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/notify"
+	"time"
+)
+
+func (h *Handler) Follow(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Follow"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	for _, pair := range [][2]uuid.UUID{{targetId, userId}, {userId, targetId}} {
+		blocked, err := h.query.IsBlocked(r.Context(), database.IsBlockedParams{
+			BlockerID: pair[0],
+			BlockedID: pair[1],
+		})
+
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "Block lookup failed", slog.String("op", op), sl.Err(err))
+			errD := sqlhelpers.GetDBError(err, "block")
+			json.WriteJSON(w, errD.StatusCode, errD)
+			return
+		}
+
+		if blocked {
+			json.WriteJSON(w, http.StatusForbidden, response.Forbidden(errBlocked.Error()))
+			return
+		}
+	}
+
+	followed, err := h.query.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userId,
+		FolloweeID: targetId,
+		CreatedAt:  time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to follow user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if followed > 0 {
+		h.notifier.Notify(r.Context(), notify.Event{
+			Type:      notify.TypeFollow,
+			Recipient: targetId,
+			Actor:     userId,
+			TargetID:  targetId,
+		})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User followed"))
+}
+
+func (h *Handler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Unfollow"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	removed, err := h.query.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userId,
+		FolloweeID: targetId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to unfollow user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if removed == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("user is not followed"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User unfollowed"))
+}
+
+func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	const op = "users.GetFollowers"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	rows, err := h.query.GetFollowers(r.Context(), database.GetFollowersParams{
+		UserID:     userId,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get followers", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := relationsResponse{Users: make([]relationUser, 0, len(rows)), Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, relationUser{ID: row.ID, Username: row.Username, CreatedAt: row.FollowedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
+
+func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	const op = "users.GetFollowing"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	rows, err := h.query.GetFollowing(r.Context(), database.GetFollowingParams{
+		UserID:     userId,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get followed users", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := relationsResponse{Users: make([]relationUser, 0, len(rows)), Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, relationUser{ID: row.ID, Username: row.Username, CreatedAt: row.FollowedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
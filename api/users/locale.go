@@ -0,0 +1,64 @@
+package users
+
+import (
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/mail/templates"
+	"poster/internal/lib/sql/sqlhelpers"
+	"slices"
+	"time"
+)
+
+type localeRequest struct {
+	Locale string `json:"locale"`
+}
+
+// SetLocale changes the language of the emails the user receives.
+func (h *Handler) SetLocale(w http.ResponseWriter, r *http.Request) {
+	const op = "users.SetLocale"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	var req localeRequest
+
+	if details, err := json.DecodeJSONBody(w, r, &req); err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid JSON body", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, details.StatusCode, details)
+		return
+	}
+
+	if !slices.Contains(templates.Locales, req.Locale) {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("unsupported locale"))
+		return
+	}
+
+	rows, err := h.query.SetUserLocale(r.Context(), database.SetUserLocaleParams{
+		ID:        userId,
+		Locale:    req.Locale,
+		UpdatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to set locale", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if rows == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("user not found"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("Locale updated"))
+}
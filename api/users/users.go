@@ -0,0 +1,304 @@
+package users
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/internal/database"
+	"poster/internal/lib/http/json"
+	"poster/internal/lib/http/pagination"
+	"poster/internal/lib/http/response"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/notify"
+	"time"
+)
+
+const label = "user"
+
+var (
+	errSelfTarget = errors.New("you cannot do this to yourself")
+	errBlocked    = errors.New("you cannot follow this user")
+)
+
+type Handler struct {
+	logger   *slog.Logger
+	query    *database.Queries
+	notifier *notify.Notifier
+}
+
+type relationUser struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type relationsResponse struct {
+	Users []relationUser `json:"users"`
+	pagination.Page
+}
+
+func RegisterRoutes(r chi.Router, handler *Handler) {
+	r.Route("/users/{id}", func(r chi.Router) {
+		r.Use(authmiddleware.JWTAuthRequired)
+		r.Post("/block", handler.Block)
+		r.Delete("/block", handler.Unblock)
+		r.Post("/mute", handler.Mute)
+		r.Delete("/mute", handler.Unmute)
+		r.Post("/follow", handler.Follow)
+		r.Delete("/follow", handler.Unfollow)
+	})
+
+	r.With(authmiddleware.JWTAuthRequired).Get("/account/blocks", handler.GetBlocks)
+	r.With(authmiddleware.JWTAuthRequired).Get("/account/mutes", handler.GetMutes)
+	r.With(authmiddleware.JWTAuthRequired).Get("/account/followers", handler.GetFollowers)
+	r.With(authmiddleware.JWTAuthRequired).Get("/account/following", handler.GetFollowing)
+	r.With(authmiddleware.JWTAuthRequired).Put("/account/locale", handler.SetLocale)
+	r.With(authmiddleware.JWTAuthRequired).Get("/account/email", handler.GetEmailStatus)
+	r.With(authmiddleware.JWTAuthRequired).Delete("/account/email/suppression", handler.ResumeEmail)
+}
+
+func NewUsersHandler(log *slog.Logger, db *database.Queries, notifier *notify.Notifier) *Handler {
+	return &Handler{
+		logger:   log,
+		query:    db,
+		notifier: notifier,
+	}
+}
+
+// parties returns the caller and the existing user named by the {id} param.
+// On failure the error response is already written.
+func (h *Handler) parties(w http.ResponseWriter, r *http.Request, op string) (uuid.UUID, uuid.UUID, bool) {
+	idAlias := chi.URLParam(r, "id")
+
+	targetId, err := uuid.Parse(idAlias)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), op, "failed to parse id as a valid uuid", sl.Err(err), slog.String("id", idAlias))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest("passed invalid id"))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if userId == targetId {
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(errSelfTarget.Error()))
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if _, err = h.query.GetUserByUUID(r.Context(), targetId); err != nil {
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userId, targetId, true
+}
+
+func (h *Handler) Block(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Block"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	_, err := h.query.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userId,
+		BlockedID: targetId,
+		CreatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to block user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	err = h.query.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		UserA: userId,
+		UserB: targetId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to remove follows of blocked user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User blocked"))
+}
+
+func (h *Handler) Unblock(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Unblock"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	removed, err := h.query.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userId,
+		BlockedID: targetId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to unblock user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if removed == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("user is not blocked"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User unblocked"))
+}
+
+func (h *Handler) Mute(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Mute"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	_, err := h.query.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID:   userId,
+		MutedID:   targetId,
+		CreatedAt: time.Now(),
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to mute user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User muted"))
+}
+
+func (h *Handler) Unmute(w http.ResponseWriter, r *http.Request) {
+	const op = "users.Unmute"
+
+	userId, targetId, ok := h.parties(w, r, op)
+
+	if !ok {
+		return
+	}
+
+	removed, err := h.query.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userId,
+		MutedID: targetId,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to unmute user", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	if removed == 0 {
+		json.WriteJSON(w, http.StatusNotFound, response.NotFound("user is not muted"))
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWMsg("User unmuted"))
+}
+
+func (h *Handler) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	const op = "users.GetBlocks"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	rows, err := h.query.GetBlockedUsers(r.Context(), database.GetBlockedUsersParams{
+		UserID:     userId,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get blocked users", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := relationsResponse{Users: make([]relationUser, 0, len(rows)), Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, relationUser{ID: row.ID, Username: row.Username, CreatedAt: row.BlockedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
+
+func (h *Handler) GetMutes(w http.ResponseWriter, r *http.Request) {
+	const op = "users.GetMutes"
+
+	userId, errD, err := authmiddleware.Identify(r, w, h.logger, op)
+
+	if err != nil {
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	page, err := pagination.FromRequest(r)
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid pagination", slog.String("op", op), sl.Err(err))
+		json.WriteJSON(w, http.StatusBadRequest, response.BadRequest(err.Error()))
+		return
+	}
+
+	rows, err := h.query.GetMutedUsers(r.Context(), database.GetMutedUsersParams{
+		UserID:     userId,
+		PageLimit:  page.Limit,
+		PageOffset: page.Offset,
+	})
+
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to get muted users", slog.String("op", op), sl.Err(err))
+		errD := sqlhelpers.GetDBError(err, label)
+		json.WriteJSON(w, errD.StatusCode, errD)
+		return
+	}
+
+	res := relationsResponse{Users: make([]relationUser, 0, len(rows)), Page: page}
+
+	for _, row := range rows {
+		res.Users = append(res.Users, relationUser{ID: row.ID, Username: row.Username, CreatedAt: row.MutedAt})
+	}
+
+	json.WriteJSON(w, http.StatusOK, response.OkWData(res))
+}
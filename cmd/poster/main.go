@@ -1,22 +1,57 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	slogchi "github.com/samber/slog-chi"
-	"gopkg.in/gomail.v2"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"poster/api/auth"
+	"poster/api/bounces"
+	eventsapi "poster/api/events"
+	healthapi "poster/api/health"
 	"poster/api/interactions"
+	"poster/api/live"
+	"poster/api/mailpreview"
+	authmiddleware "poster/api/middlewares/auth"
+	"poster/api/moderation"
+	"poster/api/notifications"
 	"poster/api/posts"
+	"poster/api/tags"
+	"poster/api/uploads"
+	"poster/api/users"
+	"poster/internal/buildinfo"
 	"poster/internal/config"
 	"poster/internal/database"
+	"poster/internal/digest"
+	"poster/internal/events"
+	"poster/internal/health"
+	"poster/internal/lib/contentfilter"
 	"poster/internal/lib/logger/prettylogger"
 	"poster/internal/lib/logger/sl"
 	"poster/internal/lib/mail/sender"
+	"poster/internal/lib/mail/templates"
+	"poster/internal/lib/reactions"
+	"poster/internal/lib/sql/sqlhelpers"
+	"poster/internal/lib/storage"
+	"poster/internal/lib/unsubscribe"
+	"poster/internal/mailqueue"
+	"poster/internal/mentions"
+	"poster/internal/metrics"
+	"poster/internal/notify"
+	"poster/internal/purge"
+	"poster/internal/thumbnails"
+	"poster/internal/tracing"
+	"poster/internal/txn"
+	"poster/sql/migrations"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -33,51 +68,185 @@ func main() {
 	logger := setupLogger(cfg.Env)
 	logger.Info("Starting money manager")
 
+	// SIGINT or SIGTERM starts the shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Tracing
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Enabled:        cfg.Tracing.Enabled,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		Headers:        cfg.Tracing.Headers,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.Tracing.ServiceName,
+		ServiceVersion: buildinfo.Get().Version,
+	})
+
+	if err != nil {
+		logger.Error("failed to set up tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
 	// Connecting to Database
 
-	db, err := sql.Open("postgres", cfg.Database.Address)
+	db, err := setupDatabase(ctx, cfg.Database)
 
 	if err != nil {
 		logger.Error("failed to connect to database", sl.Err(err))
+		os.Exit(1)
 	}
 
-	queries := database.New(db)
+	metrics.RegisterDB(db)
+	queries := database.New(instrumentDB(db))
+	txs := txn.NewRunner(db, instrumentDB)
 
 	// Mailer
-	dialer, err := cfg.Mailer.Dialer.Dial()
+
+	transport, err := setupMailTransport(cfg.Mailer)
 
 	if err != nil {
-		slog.Error("Error connecting to mailer", slog.Any("error", err))
+		logger.Error("failed to set up mail transport", sl.Err(err))
 		os.Exit(1)
 	}
 
-	defer func(dialer gomail.SendCloser) {
-		err = dialer.Close()
-		if err != nil {
-			slog.Error("Error connecting to mailer", slog.Any("error", err))
-			os.Exit(1)
-		}
-	}(dialer)
+	mailer := mailqueue.NewQueue(queries, cfg.Mailer.Email)
+
+	emails, err := templates.New()
+
+	if err != nil {
+		logger.Error("failed to load email templates", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Storage
+
+	store, err := setupStorage(cfg.Storage)
+
+	if err != nil {
+		logger.Error("failed to set up storage", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Reactions
+
+	allowedReactions, err := reactions.NewAllowList(cfg.Reactions.Allowed)
+
+	if err != nil {
+		logger.Error("invalid reactions config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Background workers. Producers create work for the mail worker, so they
+	// are stopped first and the mail worker can still deliver what they queued.
+
+	producers := newWorkers()
+	delivery := newWorkers()
+
+	sizes := make([]thumbnails.Size, 0, len(cfg.Thumbnails.Sizes))
+	for _, size := range cfg.Thumbnails.Sizes {
+		sizes = append(sizes, thumbnails.Size{Name: size.Name, Width: size.Width, Height: size.Height})
+	}
+
+	thumbnailer := thumbnails.NewWorker(logger, queries, store, sizes, cfg.Thumbnails.PollInterval, cfg.Thumbnails.BatchSize)
+	producers.Go(thumbnailer.Run)
 
-	mailer := sender.NewSender(cfg.Mailer.Email, cfg.Mailer.Dialer)
+	purger := purge.NewWorker(logger, queries, cfg.Retention.Window, cfg.Retention.PurgeInterval)
+	producers.Go(purger.Run)
+
+	mailWorker := mailqueue.NewWorker(logger, queries, transport, mailqueue.Options{
+		Workers:      cfg.MailQueue.Workers,
+		BatchSize:    cfg.MailQueue.BatchSize,
+		PollInterval: cfg.MailQueue.PollInterval,
+		MaxAttempts:  cfg.MailQueue.MaxAttempts,
+		BaseBackoff:  cfg.MailQueue.BaseBackoff,
+		MaxBackoff:   cfg.MailQueue.MaxBackoff,
+	})
+	delivery.Go(mailWorker.Run)
+
+	signer := unsubscribe.NewSigner(cfg.Digest.UnsubscribeSecret)
+	digester := digest.NewWorker(logger, queries, mailer, emails, signer, cfg.Digest.BaseURL, cfg.Digest.Interval)
+	producers.Go(digester.Run)
 
 	// Routes
 
 	router := chi.NewRouter()
-	router.Use(slogchi.New(logger))
+	// Tracing goes first, so the request log and metrics see the span.
+	router.Use(tracing.Middleware)
+	// Probes hit the service every few seconds and would flood the log.
+	router.Use(slogchi.NewWithFilters(logger, slogchi.IgnorePath("/healthz", "/readyz")))
+	router.Use(metrics.Middleware)
 
-	usersHandlers := auth.NewAuthHandler(logger, queries, mailer)
+	authmiddleware.SetSuspensionChecker(moderation.SuspensionChecker(queries))
+
+	usersHandlers := auth.NewAuthHandler(logger, queries, mailer, emails)
 	auth.RegisterRoutes(router, usersHandlers)
 
-	postsHandlers := posts.NewPostsHandler(logger, queries)
+	filter := setupContentFilter(cfg.Filter, queries)
+	hub := events.NewHub(cfg.Events.Buffer, cfg.Events.History)
+	notifier := notify.NewNotifier(logger, queries, hub)
+	mentioner := mentions.NewMentioner(queries, notifier)
+
+	postsHandlers := posts.NewPostsHandler(logger, txs, queries, cfg.Retention.Window, filter, mentioner)
 	posts.RegisterRoutes(router, postsHandlers)
 
-	interactionsHandlers := interactions.NewInteractionsHandlers(logger, queries)
+	interactionsHandlers := interactions.NewInteractionsHandlers(logger, txs, queries, allowedReactions, cfg.Retention.Window, filter, notifier, hub, mentioner)
 	interactions.RegisterRoutes(router, interactionsHandlers)
 
-	// Serving
+	moderationHandlers := moderation.NewModerationHandler(logger, txs, queries)
+	moderation.RegisterRoutes(router, moderationHandlers)
 
-	logger.Info("✅ Server started", slog.String("address", cfg.HTTPServer.Address))
+	relationsHandlers := users.NewUsersHandler(logger, queries, notifier)
+	users.RegisterRoutes(router, relationsHandlers)
+
+	notificationsHandlers := notifications.NewNotificationsHandler(logger, queries, signer)
+	notifications.RegisterRoutes(router, notificationsHandlers)
+
+	eventsHandlers := eventsapi.NewEventsHandler(logger, queries, hub, cfg.Events.Heartbeat)
+	eventsapi.RegisterRoutes(router, eventsHandlers)
+
+	liveHandlers := live.NewLiveHandler(logger, queries, hub, live.Options{
+		PingInterval: cfg.Events.PingInterval,
+		PongWait:     cfg.Events.PongWait,
+		WriteWait:    cfg.Events.WriteWait,
+	})
+	live.RegisterRoutes(router, liveHandlers)
+
+	tagsHandlers := tags.NewTagsHandler(logger, queries)
+	tags.RegisterRoutes(router, tagsHandlers)
+
+	uploadsHandlers := uploads.NewUploadsHandler(logger, queries, store, uploads.Options{
+		MaxSize:      cfg.Storage.MaxUploadSize,
+		AllowedTypes: cfg.Storage.AllowedTypes,
+	})
+	uploads.RegisterRoutes(router, uploadsHandlers)
+
+	bouncesHandlers := bounces.NewBouncesHandler(logger, queries, cfg.MailQueue.BounceSecret)
+	bounces.RegisterRoutes(router, bouncesHandlers)
+
+	latestMigration, err := migrations.Latest()
+
+	if err != nil {
+		logger.Error("failed to read migrations", sl.Err(err))
+		os.Exit(1)
+	}
+
+	checker := health.NewChecker(cfg.Health.Timeout,
+		health.Database(db),
+		health.Migrations(db, latestMigration),
+		health.Mail(transport),
+	)
+
+	healthHandlers := healthapi.NewHealthHandler(logger, checker)
+	healthapi.RegisterRoutes(router, healthHandlers)
+
+	if cfg.Env == "dev" || cfg.Env == "local" {
+		mailPreviewHandlers := mailpreview.NewMailPreviewHandler(logger, emails)
+		mailpreview.RegisterRoutes(router, mailPreviewHandlers)
+	}
+
+	// Serving
 
 	srv := &http.Server{
 		Addr:         cfg.HTTPServer.Address,
@@ -87,41 +256,243 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	if err = srv.ListenAndServe(); err != nil {
+	srv.RegisterOnShutdown(hub.Close)
+
+	serverErr := make(chan error, 2)
+
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	logger.Info("✅ Server started", slog.String("address", cfg.HTTPServer.Address))
+
+	// Metrics are served on a separate, internal listener so the public
+	// router never exposes them.
+	metricsSrv := setupMetricsServer(cfg.Metrics.Address)
+
+	if metricsSrv != nil {
+		go func() {
+			serverErr <- metricsSrv.ListenAndServe()
+		}()
+
+		logger.Info("Metrics server started", slog.String("address", cfg.Metrics.Address))
+	}
+
+	exitCode := 0
+
+	select {
+	case err = <-serverErr:
 		logger.Error("failed to start server", sl.Err(err))
-		os.Exit(1)
+		exitCode = 1
+	case <-ctx.Done():
+		logger.Info("Shutting down")
+	}
+
+	// Shutting down: stop taking requests and let the in-flight ones finish,
+	// then the workers, then release the connections they used.
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	if err = srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to drain requests", sl.Err(err))
+		exitCode = 1
+	}
+
+	if metricsSrv != nil {
+		if err = metricsSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("failed to stop metrics server", sl.Err(err))
+			exitCode = 1
+		}
+	}
+
+	if err = producers.Stop(shutdownCtx); err != nil {
+		logger.Error("failed to stop background workers", sl.Err(err))
+		exitCode = 1
+	}
+
+	if err = delivery.Stop(shutdownCtx); err != nil {
+		logger.Error("failed to stop mail worker", sl.Err(err))
+		exitCode = 1
+	}
+
+	if err = transport.Close(); err != nil {
+		logger.Error("failed to close mail transport", sl.Err(err))
+	}
+
+	if err = shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("failed to flush traces", sl.Err(err))
+	}
+
+	if err = db.Close(); err != nil {
+		logger.Error("failed to close database", sl.Err(err))
+	}
+
+	logger.Info("Server stopped")
+
+	os.Exit(exitCode)
+}
+
+func setupDatabase(ctx context.Context, cfg config.Database) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.Address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.PingTimeout)
+	defer cancel()
+
+	if err = db.PingContext(pingCtx); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
+	return db, nil
+}
+
+// workers runs background workers until they are stopped together.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+func (w *workers) Go(run func(ctx context.Context)) {
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		run(w.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, or for ctx to end.
+func (w *workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func setupContentFilter(cfg config.Filter, queries *database.Queries) *contentfilter.Pipeline {
+	return contentfilter.NewPipeline(
+		contentfilter.NewBannedWords(cfg.BannedWords, cfg.SuspectWords),
+		&contentfilter.LinkLimit{
+			Max:           cfg.NewAccountMaxLinks,
+			NewAccountAge: cfg.NewAccountAge,
+			Created: func(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+				u, err := queries.GetUserByUUID(ctx, userID)
+				return u.CreatedAt, err
+			},
+		},
+		&contentfilter.Duplicates{
+			Window:    cfg.DuplicateWindow,
+			MinLength: cfg.DuplicateMinLength,
+			Count: func(ctx context.Context, authorID uuid.UUID, fingerprint string, since time.Time, excludeID uuid.UUID) (int64, error) {
+				return queries.CountRecentDuplicates(ctx, database.CountRecentDuplicatesParams{
+					AuthorID:    authorID,
+					Since:       since,
+					ExcludeID:   excludeID,
+					Fingerprint: fingerprint,
+				})
+			},
+		},
+	)
 }
 
 func setupLogger(level string) *slog.Logger {
 
-	var log *slog.Logger
+	var handler slog.Handler
 
 	if level == "dev" {
-		prettyHandler := prettylogger.NewHandler(&slog.HandlerOptions{
+		handler = prettylogger.NewHandler(&slog.HandlerOptions{
 			Level:       slog.LevelInfo,
 			AddSource:   false,
 			ReplaceAttr: nil,
 		})
-
-		log = slog.New(prettyHandler)
 	} else if level == "prod" {
-		log = slog.New(
-			slog.NewJSONHandler(
-				os.Stdout,
-				&slog.HandlerOptions{Level: slog.LevelInfo},
-			),
+		handler = slog.NewJSONHandler(
+			os.Stdout,
+			&slog.HandlerOptions{Level: slog.LevelInfo},
 		)
 	} else {
-		log = slog.New(
-			slog.NewTextHandler(
-				os.Stdout,
-				&slog.HandlerOptions{Level: slog.LevelInfo},
-			),
+		handler = slog.NewTextHandler(
+			os.Stdout,
+			&slog.HandlerOptions{Level: slog.LevelInfo},
 		)
 	}
 
-	return log
+	return slog.New(tracing.NewLogHandler(handler))
+
+}
+
+func setupMailTransport(cfg config.Mailer) (sender.Transport, error) {
+	switch cfg.Transport {
+	case "file":
+		return sender.NewFileSink(cfg.Email, cfg.Dir)
+	case "memory":
+		return sender.NewMemory(cfg.Email), nil
+	}
+
+	return sender.NewSMTPPool(cfg.Email, cfg.Dialer, cfg.PoolSize, cfg.IdleTimeout), nil
+}
+
+// instrumentDB wraps the connection pool and every transaction, so all
+// queries are observed alike.
+func instrumentDB(db sqlhelpers.DBTX) sqlhelpers.DBTX {
+	return tracing.NewDB(metrics.NewDB(db))
+}
+
+// setupMetricsServer returns the server for the Prometheus endpoint, or nil
+// when it is turned off.
+func setupMetricsServer(address string) *http.Server {
+	if address == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+func setupStorage(cfg config.Storage) (storage.Storage, error) {
+	if cfg.Driver == "s3" {
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		}, nil), nil
+	}
 
+	return storage.NewLocal(cfg.Local.Dir)
 }
@@ -5,7 +5,9 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"gopkg.in/gomail.v2"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,17 @@ type Config struct {
 	HTTPServer HTTPServer `yaml:"http_server" env:"HTTP_SERVER"`
 	Database   Database   `yaml:"database" env:"DATABASE"`
 	Mailer     Mailer     `yaml:"mailer" env:"MAILER"`
+	Storage    Storage    `yaml:"storage" env:"STORAGE"`
+	Thumbnails Thumbnails `yaml:"thumbnails" env:"THUMBNAILS"`
+	Reactions  Reactions  `yaml:"reactions" env:"REACTIONS"`
+	Retention  Retention  `yaml:"retention" env:"RETENTION"`
+	Filter     Filter     `yaml:"content_filter" env:"CONTENT_FILTER"`
+	Events     Events     `yaml:"events" env:"EVENTS"`
+	Digest     Digest     `yaml:"digest" env:"DIGEST"`
+	MailQueue  MailQueue  `yaml:"mail_queue" env:"MAIL_QUEUE"`
+	Health     Health     `yaml:"health" env:"HEALTH"`
+	Tracing    Tracing    `yaml:"tracing" env:"TRACING"`
+	Metrics    Metrics    `yaml:"metrics" env:"METRICS"`
 }
 
 type Database struct {
@@ -23,6 +36,14 @@ type Database struct {
 	User     string `yaml:"user" env:"USER" env-default:"user"`
 	Password string `yaml:"password" env:"PASSWORD"`
 	Address  string
+
+	// Connection pool settings, see sql.DB. PingTimeout bounds the startup
+	// connectivity check.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DATABASE_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DATABASE_MAX_IDLE_CONNS" env-default:"25"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DATABASE_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DATABASE_CONN_MAX_IDLE_TIME" env-default:"5m"`
+	PingTimeout     time.Duration `yaml:"ping_timeout" env:"DATABASE_PING_TIMEOUT" env-default:"5s"`
 }
 
 type HTTPServer struct {
@@ -31,6 +52,9 @@ type HTTPServer struct {
 	Port        string        `yaml:"port" env:"HTTP_PORT" default:"8080"`
 	Timeout     time.Duration `yaml:"timeout" env:"HTTP_TIMEOUT" default:"5"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"5"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once the server is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"15s"`
 }
 
 type Mailer struct {
@@ -40,8 +64,131 @@ type Mailer struct {
 	Email    string         `yaml:"sender" env:"MAILER_EMAIL" default:"test@test.com"`
 	Password string         `yaml:"password" env:"MAILER_PASSWORD" default:"test"`
 	Dialer   *gomail.Dialer `yaml:"dialer" env:"MAILER_DIALER"`
+
+	// Transport is "smtp", "file" (write .eml files to Dir) or "memory".
+	Transport   string        `yaml:"transport" env:"MAILER_TRANSPORT" env-default:"smtp"`
+	Dir         string        `yaml:"dir" env:"MAILER_DIR" env-default:"./mail"`
+	PoolSize    int           `yaml:"pool_size" env:"MAILER_POOL_SIZE" env-default:"2"`
+	IdleTimeout time.Duration `yaml:"idle_timeout" env:"MAILER_IDLE_TIMEOUT" env-default:"30s"`
+}
+
+type Storage struct {
+	Driver        string    `yaml:"driver" env:"STORAGE_DRIVER" env-default:"local"`
+	MaxUploadSize int64     `yaml:"max_upload_size" env:"STORAGE_MAX_UPLOAD_SIZE" env-default:"10485760"`
+	AllowedTypes  []string  `yaml:"allowed_types" env:"STORAGE_ALLOWED_TYPES" env-default:"image/png,image/jpeg,image/gif,image/webp"`
+	Local         LocalDisk `yaml:"local"`
+	S3            S3        `yaml:"s3"`
+}
+
+type LocalDisk struct {
+	Dir string `yaml:"dir" env:"STORAGE_LOCAL_DIR" env-default:"./uploads"`
+}
+
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" env:"STORAGE_S3_ENDPOINT"`
+	Region    string `yaml:"region" env:"STORAGE_S3_REGION" env-default:"us-east-1"`
+	Bucket    string `yaml:"bucket" env:"STORAGE_S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"STORAGE_S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" env:"STORAGE_S3_SECRET_KEY"`
+}
+
+type Thumbnails struct {
+	PollInterval time.Duration   `yaml:"poll_interval" env:"THUMBNAILS_POLL_INTERVAL" env-default:"5s"`
+	BatchSize    int32           `yaml:"batch_size" env:"THUMBNAILS_BATCH_SIZE" env-default:"10"`
+	Sizes        []ThumbnailSize `yaml:"sizes"`
+}
+
+type ThumbnailSize struct {
+	Name   string `yaml:"name"`
+	Width  int    `yaml:"width"`
+	Height int    `yaml:"height"`
+}
+
+type Reactions struct {
+	Allowed []string `yaml:"allowed" env:"REACTIONS_ALLOWED" env-default:"👍,❤️,😂,😮,😢,😡"`
+}
+
+// Retention controls how long soft-deleted posts and comments can be restored
+// before the purge job removes them for good.
+type Retention struct {
+	Window        time.Duration `yaml:"window" env:"RETENTION_WINDOW" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"RETENTION_PURGE_INTERVAL" env-default:"1h"`
+}
+
+// Filter configures the spam checks run on new and edited posts and comments.
+// Banned words reject the content, suspect words hold it for review.
+type Filter struct {
+	BannedWords        []string      `yaml:"banned_words" env:"FILTER_BANNED_WORDS"`
+	SuspectWords       []string      `yaml:"suspect_words" env:"FILTER_SUSPECT_WORDS"`
+	NewAccountAge      time.Duration `yaml:"new_account_age" env:"FILTER_NEW_ACCOUNT_AGE" env-default:"72h"`
+	NewAccountMaxLinks int           `yaml:"new_account_max_links" env:"FILTER_NEW_ACCOUNT_MAX_LINKS" env-default:"2"`
+	DuplicateWindow    time.Duration `yaml:"duplicate_window" env:"FILTER_DUPLICATE_WINDOW" env-default:"24h"`
+	DuplicateMinLength int           `yaml:"duplicate_min_length" env:"FILTER_DUPLICATE_MIN_LENGTH" env-default:"30"`
 }
 
+// Events configures the Server-Sent Events stream and the live WebSocket.
+// Buffer is how many events a connection may lag behind before it is
+// dropped, History how many recent events are kept for Last-Event-ID resume.
+type Events struct {
+	Heartbeat    time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" env-default:"15s"`
+	Buffer       int           `yaml:"buffer" env:"EVENTS_BUFFER" env-default:"64"`
+	History      int           `yaml:"history" env:"EVENTS_HISTORY" env-default:"1024"`
+	PingInterval time.Duration `yaml:"ping_interval" env:"EVENTS_PING_INTERVAL" env-default:"30s"`
+	PongWait     time.Duration `yaml:"pong_wait" env:"EVENTS_PONG_WAIT" env-default:"70s"`
+	WriteWait    time.Duration `yaml:"write_wait" env:"EVENTS_WRITE_WAIT" env-default:"10s"`
+}
+
+// Digest configures notification emails. BaseURL is the public address the
+// unsubscribe links in them point to, UnsubscribeSecret signs those links.
+type Digest struct {
+	Interval          time.Duration `yaml:"interval" env:"DIGEST_INTERVAL" env-default:"1m"`
+	BaseURL           string        `yaml:"base_url" env:"DIGEST_BASE_URL" env-default:"http://localhost:8080"`
+	UnsubscribeSecret string        `yaml:"unsubscribe_secret" env:"DIGEST_UNSUBSCRIBE_SECRET"`
+}
+
+// MailQueue configures delivery of queued emails. Failed deliveries are
+// retried after BaseBackoff, doubling up to MaxBackoff, until MaxAttempts.
+// BounceSecret authenticates the bounce and complaint webhook.
+type MailQueue struct {
+	Workers      int           `yaml:"workers" env:"MAIL_QUEUE_WORKERS" env-default:"4"`
+	BatchSize    int32         `yaml:"batch_size" env:"MAIL_QUEUE_BATCH_SIZE" env-default:"20"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"MAIL_QUEUE_POLL_INTERVAL" env-default:"5s"`
+	MaxAttempts  int32         `yaml:"max_attempts" env:"MAIL_QUEUE_MAX_ATTEMPTS" env-default:"8"`
+	BaseBackoff  time.Duration `yaml:"base_backoff" env:"MAIL_QUEUE_BASE_BACKOFF" env-default:"30s"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"MAIL_QUEUE_MAX_BACKOFF" env-default:"1h"`
+	BounceSecret string        `yaml:"bounce_secret" env:"MAIL_QUEUE_BOUNCE_SECRET"`
+}
+
+// Health configures the readiness probe. Each check is cut off after Timeout.
+type Health struct {
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" env-default:"2s"`
+}
+
+// Metrics configures the Prometheus endpoint. It is served on its own
+// listener at Address, which should not be reachable from the internet; an
+// empty Address turns the endpoint off.
+type Metrics struct {
+	Address string `yaml:"address" env:"METRICS_ADDRESS" env-default:"localhost:9090"`
+}
+
+// Tracing configures OpenTelemetry. Spans are exported over OTLP/HTTP to
+// Endpoint (host:port); SampleRatio is the share of new traces recorded.
+type Tracing struct {
+	Enabled     bool              `yaml:"enabled" env:"TRACING_ENABLED" env-default:"false"`
+	Endpoint    string            `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	Insecure    bool              `yaml:"insecure" env:"TRACING_INSECURE" env-default:"true"`
+	Headers     map[string]string `yaml:"headers" env:"TRACING_HEADERS"`
+	SampleRatio float64           `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	ServiceName string            `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"poster"`
+}
+
+var defaultThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Width: 320, Height: 320},
+	{Name: "medium", Width: 1024, Height: 1024},
+}
+
+var thumbnailNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
 const PathKey = "CONFIG_PATH"
 
 func New() (*Config, error) {
@@ -69,12 +216,87 @@ func New() (*Config, error) {
 		cfg.Database.User, cfg.Database.Host, cfg.Database.Port, cfg.Database.Name,
 	)
 
+	if cfg.Database.MaxOpenConns < 0 || cfg.Database.MaxIdleConns < 0 || cfg.Database.PingTimeout <= 0 {
+		return nil, fmt.Errorf("invalid database pool settings")
+	}
+
+	if cfg.HTTPServer.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("http shutdown timeout must be positive")
+	}
+
 	mailerPort, err := strconv.Atoi(cfg.Mailer.Port)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid mailer port: %w", err)
 	}
 
+	switch cfg.Mailer.Transport {
+	case "smtp", "file", "memory":
+	default:
+		return nil, fmt.Errorf("unknown mail transport: %s", cfg.Mailer.Transport)
+	}
+
+	if cfg.Mailer.PoolSize <= 0 {
+		return nil, fmt.Errorf("mailer pool size must be positive")
+	}
+
+	if cfg.Storage.Driver != "local" && cfg.Storage.Driver != "s3" {
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Storage.Driver)
+	}
+
+	if len(cfg.Thumbnails.Sizes) == 0 {
+		cfg.Thumbnails.Sizes = defaultThumbnailSizes
+	}
+
+	for _, size := range cfg.Thumbnails.Sizes {
+		if !thumbnailNameRe.MatchString(size.Name) || size.Width <= 0 || size.Height <= 0 {
+			return nil, fmt.Errorf("invalid thumbnail size: %+v", size)
+		}
+	}
+
+	if cfg.Retention.Window <= 0 || cfg.Retention.PurgeInterval <= 0 {
+		return nil, fmt.Errorf("retention window and purge interval must be positive")
+	}
+
+	if cfg.Filter.NewAccountMaxLinks < 0 || cfg.Filter.DuplicateWindow < 0 || cfg.Filter.DuplicateMinLength < 0 {
+		return nil, fmt.Errorf("content filter limits must not be negative")
+	}
+
+	if cfg.Events.Heartbeat <= 0 || cfg.Events.Buffer <= 0 || cfg.Events.History < 0 {
+		return nil, fmt.Errorf("invalid events settings")
+	}
+
+	if cfg.Events.PingInterval <= 0 || cfg.Events.WriteWait <= 0 || cfg.Events.PongWait <= cfg.Events.PingInterval {
+		return nil, fmt.Errorf("live pong wait must be longer than the ping interval")
+	}
+
+	if cfg.Digest.Interval <= 0 {
+		return nil, fmt.Errorf("digest interval must be positive")
+	}
+
+	if cfg.Digest.UnsubscribeSecret == "" {
+		return nil, fmt.Errorf("digest unsubscribe secret is required")
+	}
+
+	cfg.Digest.BaseURL = strings.TrimRight(cfg.Digest.BaseURL, "/")
+
+	mq := cfg.MailQueue
+	if mq.Workers <= 0 || mq.BatchSize <= 0 || mq.PollInterval <= 0 || mq.MaxAttempts <= 0 || mq.BaseBackoff <= 0 || mq.MaxBackoff < mq.BaseBackoff {
+		return nil, fmt.Errorf("invalid mail queue settings")
+	}
+
+	if mq.BounceSecret == "" {
+		return nil, fmt.Errorf("mail queue bounce secret is required")
+	}
+
+	if cfg.Health.Timeout <= 0 {
+		return nil, fmt.Errorf("health check timeout must be positive")
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio must be between 0 and 1")
+	}
+
 	dialer := gomail.NewDialer(cfg.Mailer.Host, mailerPort, cfg.Mailer.Email, cfg.Mailer.Password)
 
 	cfg.Mailer.Dialer = dialer
@@ -0,0 +1,199 @@
+package digest
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/url"
+	"poster/internal/database"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/mail/sender"
+	"poster/internal/lib/mail/templates"
+	"poster/internal/lib/unsubscribe"
+	"poster/internal/notify"
+	"time"
+)
+
+const batchSize = 100
+
+// retryAfter is how long a recipient whose email failed is skipped.
+const retryAfter = time.Hour
+
+// schedule lists how often each frequency is mailed. Immediate emails go out
+// on every run, the digests once their period has passed.
+var schedule = []struct {
+	frequency string
+	every     time.Duration
+}{
+	{notify.FrequencyImmediate, 0},
+	{notify.FrequencyDaily, 24 * time.Hour},
+	{notify.FrequencyWeekly, 7 * 24 * time.Hour},
+}
+
+// Worker emails unread notifications to users who opted in, either one by
+// one shortly after they happen or batched into daily and weekly digests.
+type Worker struct {
+	logger   *slog.Logger
+	query    *database.Queries
+	mailer   sender.MailSender
+	emails   *templates.Registry
+	signer   *unsubscribe.Signer
+	baseURL  string
+	interval time.Duration
+}
+
+func NewWorker(log *slog.Logger, db *database.Queries, mailer sender.MailSender, emails *templates.Registry, signer *unsubscribe.Signer, baseURL string, interval time.Duration) *Worker {
+	return &Worker{
+		logger:   log,
+		query:    db,
+		mailer:   mailer,
+		emails:   emails,
+		signer:   signer,
+		baseURL:  baseURL,
+		interval: interval,
+	}
+}
+
+// Run sends due emails until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, s := range schedule {
+			w.send(ctx, s.frequency, time.Now().Add(-s.every))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) send(ctx context.Context, frequency string, dueBefore time.Time) {
+	const op = "digest.Worker.send"
+
+	recipients, err := w.query.GetDigestRecipients(ctx, database.GetDigestRecipientsParams{
+		RetryBefore: time.Now().Add(-retryAfter),
+		Frequency:   frequency,
+		DueBefore:   dueBefore,
+		BatchSize:   batchSize,
+	})
+
+	if err != nil {
+		w.logger.ErrorContext(ctx, "Failed to get digest recipients", slog.String("op", op), slog.String("frequency", frequency), sl.Err(err))
+		return
+	}
+
+	for _, rcpt := range recipients {
+		if err := w.sendTo(ctx, frequency, rcpt); err != nil {
+			w.logger.ErrorContext(ctx, "Failed to send notification email",
+				slog.String("op", op),
+				slog.String("frequency", frequency),
+				slog.String("user_id", rcpt.UserID.String()),
+				sl.Err(err),
+			)
+
+			w.backOff(ctx, rcpt.UserID)
+		}
+	}
+}
+
+// backOff moves a recipient whose email failed out of the next batches.
+func (w *Worker) backOff(ctx context.Context, userID uuid.UUID) {
+	const op = "digest.Worker.backOff"
+
+	err := w.query.SetDigestFailed(ctx, database.SetDigestFailedParams{FailedAt: time.Now(), UserID: userID})
+
+	if err != nil {
+		w.logger.ErrorContext(ctx, "Failed to record digest failure", slog.String("op", op), slog.String("user_id", userID.String()), sl.Err(err))
+	}
+}
+
+func (w *Worker) sendTo(ctx context.Context, frequency string, rcpt database.GetDigestRecipientsRow) error {
+	rows, err := w.query.GetUnsentNotifications(ctx, database.GetUnsentNotificationsParams{
+		UserID:    rcpt.UserID,
+		Frequency: frequency,
+	})
+
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	items := make([]Item, 0, len(rows))
+	categories := make(map[string]struct{})
+
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+		items = append(items, Item{Type: row.Type, Actor: row.ActorUsername, Count: row.ActorCount})
+		categories[notify.CategoryOf(row.Type)] = struct{}{}
+	}
+
+	links := make([]Link, 0, len(categories)+1)
+
+	for _, category := range []string{notify.CategoryReplies, notify.CategoryMentions, notify.CategoryFollows} {
+		if _, ok := categories[category]; ok {
+			links = append(links, Link{Category: category, URL: w.unsubscribeURL(rcpt.UserID, category)})
+		}
+	}
+
+	links = append(links, Link{Category: unsubscribe.All, URL: w.unsubscribeURL(rcpt.UserID, unsubscribe.All)})
+
+	m, err := w.emails.Message(rcpt.Email, templates.Digest, rcpt.Locale, Digest{
+		Username:  rcpt.Username,
+		Frequency: frequency,
+		Items:     items,
+		Links:     links,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	m.SetHeader("List-Unsubscribe", "<"+w.unsubscribeURL(rcpt.UserID, unsubscribe.All)+">")
+	m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+
+	if err = w.mailer.Send(m); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if err = w.query.MarkNotificationsEmailed(ctx, database.MarkNotificationsEmailedParams{EmailedAt: now, Ids: ids}); err != nil {
+		return err
+	}
+
+	return w.query.SetDigestSent(ctx, database.SetDigestSentParams{
+		Frequency: frequency,
+		SentAt:    now,
+		UserID:    rcpt.UserID,
+	})
+}
+
+func (w *Worker) unsubscribeURL(userID uuid.UUID, category string) string {
+	return w.baseURL + "/unsubscribe?token=" + url.QueryEscape(w.signer.Token(userID, category))
+}
+
+// Digest is the data of the digest email template.
+type Digest struct {
+	Username  string
+	Frequency string
+	Items     []Item
+	Links     []Link
+}
+
+// Item is one notification; Count is the number of users behind it.
+type Item struct {
+	Type  string
+	Actor string
+	Count int64
+}
+
+// Link unsubscribes from Category, or from every email for unsubscribe.All.
+type Link struct {
+	Category string
+	URL      string
+}
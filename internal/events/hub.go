@@ -0,0 +1,190 @@
+package events
+
+import (
+	"sync"
+)
+
+// Event is a message delivered to subscribers of Topic. IDs are assigned by
+// the hub and increase monotonically, so clients can resume after them.
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  any
+}
+
+// Subscription receives the events published to its topics on C. C is closed
+// when the subscription is cancelled, falls too far behind or the hub shuts
+// down.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	topics map[string]struct{}
+	hub    *Hub
+	once   sync.Once
+}
+
+// Hub is an in-process publish/subscribe broker. It keeps the last events in
+// a ring buffer so reconnecting clients can replay what they missed.
+type Hub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	subs    map[*Subscription]struct{}
+	history []Event
+	start   int
+	buffer  int
+	closed  bool
+}
+
+// NewHub creates a hub whose subscribers may lag buffer events behind before
+// being dropped and which remembers the last history events for replay.
+func NewHub(buffer, history int) *Hub {
+	return &Hub{
+		subs:    make(map[*Subscription]struct{}),
+		history: make([]Event, 0, history),
+		buffer:  buffer,
+	}
+}
+
+func UserTopic(id string) string {
+	return "user:" + id
+}
+
+func PostTopic(id string) string {
+	return "post:" + id
+}
+
+// Publish sends an event to every subscriber of topic. It never blocks: a
+// subscriber whose buffer is full is disconnected and has to resume with
+// Last-Event-ID.
+func (h *Hub) Publish(topic, eventType string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.nextID++
+	ev := Event{ID: h.nextID, Topic: topic, Type: eventType, Data: data}
+	h.remember(ev)
+
+	for sub := range h.subs {
+		if _, ok := sub.topics[topic]; !ok {
+			continue
+		}
+
+		select {
+		case sub.ch <- ev:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// Subscribe registers interest in topics. Events after lastID that are still
+// in the history are returned for replay; events published from now on
+// arrive on the subscription's channel.
+func (h *Hub) Subscribe(topics []string, lastID uint64) (*Subscription, []Event) {
+	ch := make(chan Event, h.buffer)
+	sub := &Subscription{C: ch, ch: ch, topics: make(map[string]struct{}, len(topics)), hub: h}
+
+	for _, t := range topics {
+		sub.topics[t] = struct{}{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return sub, nil
+	}
+
+	var missed []Event
+
+	if lastID > 0 {
+		for i := 0; i < len(h.history); i++ {
+			ev := h.history[(h.start+i)%len(h.history)]
+
+			if _, ok := sub.topics[ev.Topic]; ok && ev.ID > lastID {
+				missed = append(missed, ev)
+			}
+		}
+	}
+
+	h.subs[sub] = struct{}{}
+
+	return sub, missed
+}
+
+// Close disconnects all subscribers. Later publishes are ignored.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	for sub := range h.subs {
+		h.drop(sub)
+	}
+}
+
+// Add subscribes to more topics. Past events of these topics are not
+// replayed.
+func (s *Subscription) Add(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+}
+
+// Remove stops delivery of topics.
+func (s *Subscription) Remove(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// Len returns the number of topics the subscription listens to.
+func (s *Subscription) Len() int {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	return len(s.topics)
+}
+
+// Cancel stops the subscription and closes its channel.
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.drop(s)
+}
+
+// drop must be called with h.mu held.
+func (h *Hub) drop(sub *Subscription) {
+	delete(h.subs, sub)
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// remember must be called with h.mu held.
+func (h *Hub) remember(ev Event) {
+	if cap(h.history) == 0 {
+		return
+	}
+
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, ev)
+		return
+	}
+
+	h.history[h.start] = ev
+	h.start = (h.start + 1) % len(h.history)
+}
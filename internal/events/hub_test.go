@@ -0,0 +1,127 @@
+package events
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func drain(sub *Subscription) []Event {
+	var got []Event
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return got
+			}
+			got = append(got, ev)
+		default:
+			return got
+		}
+	}
+}
+
+func TestHub_Publish(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("delivers only subscribed topics", func(t *testing.T) {
+		h := NewHub(8, 16)
+		sub, missed := h.Subscribe([]string{"post:1"}, 0)
+		assert.Empty(missed)
+
+		h.Publish("post:1", "comment", "a")
+		h.Publish("post:2", "comment", "b")
+		h.Publish("post:1", "like", "c")
+
+		got := drain(sub)
+		assert.Len(got, 2)
+		assert.Equal("a", got[0].Data)
+		assert.Equal("like", got[1].Type)
+		assert.Less(got[0].ID, got[1].ID)
+	})
+
+	t.Run("drops slow subscribers", func(t *testing.T) {
+		h := NewHub(1, 16)
+		sub, _ := h.Subscribe([]string{"t"}, 0)
+
+		h.Publish("t", "x", 1)
+		h.Publish("t", "x", 2)
+
+		ev, ok := <-sub.C
+		assert.True(ok)
+		assert.Equal(1, ev.Data)
+
+		_, ok = <-sub.C
+		assert.False(ok, "channel should be closed after overflow")
+	})
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("replays events after last id", func(t *testing.T) {
+		h := NewHub(8, 16)
+		h.Publish("t", "x", 1)
+		h.Publish("other", "x", 2)
+		h.Publish("t", "x", 3)
+		h.Publish("t", "x", 4)
+
+		_, missed := h.Subscribe([]string{"t"}, 1)
+		assert.Len(missed, 2)
+		assert.Equal(3, missed[0].Data)
+		assert.Equal(4, missed[1].Data)
+	})
+
+	t.Run("history is bounded", func(t *testing.T) {
+		h := NewHub(8, 2)
+		for i := 1; i <= 5; i++ {
+			h.Publish("t", "x", i)
+		}
+
+		_, missed := h.Subscribe([]string{"t"}, 1)
+		assert.Len(missed, 2)
+		assert.Equal(4, missed[0].Data)
+		assert.Equal(5, missed[1].Data)
+	})
+}
+
+func TestSubscription_AddRemove(t *testing.T) {
+	assert := assert2.New(t)
+
+	h := NewHub(8, 16)
+	sub, _ := h.Subscribe(nil, 0)
+	assert.Equal(0, sub.Len())
+
+	sub.Add("a", "b")
+	assert.Equal(2, sub.Len())
+
+	h.Publish("a", "x", 1)
+	sub.Remove("a")
+	h.Publish("a", "x", 2)
+	h.Publish("b", "x", 3)
+
+	got := drain(sub)
+	assert.Len(got, 2)
+	assert.Equal(1, got[0].Data)
+	assert.Equal(3, got[1].Data)
+}
+
+func TestHub_Close(t *testing.T) {
+	assert := assert2.New(t)
+
+	h := NewHub(8, 16)
+	sub, _ := h.Subscribe([]string{"t"}, 0)
+	other, _ := h.Subscribe([]string{"t"}, 0)
+	other.Cancel()
+	other.Cancel()
+
+	h.Close()
+	h.Publish("t", "x", 1)
+
+	_, ok := <-sub.C
+	assert.False(ok)
+
+	late, _ := h.Subscribe([]string{"t"}, 0)
+	_, ok = <-late.C
+	assert.False(ok, "subscriptions after close are closed immediately")
+}
@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var errTimeout = errors.New("check timed out")
+
+// Check is one dependency the service needs to serve requests. An optional
+// check is reported but does not take the report down.
+type Check struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Optional   bool   `json:"optional,omitempty"`
+}
+
+// Report is up only if every check that is not optional is.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs its checks concurrently, each bounded by timeout.
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, check := range c.checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[check.Name] = res
+			if res.Status != StatusUp && !check.Optional {
+				report.Status = StatusDown
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// run gives up on checks that ignore ctx once the timeout passes; they are
+// left to finish in the background.
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimeout
+	}
+
+	res := Result{Status: StatusUp, DurationMs: time.Since(start).Milliseconds(), Optional: check.Optional}
+
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// Database pings the database.
+func Database(db *sql.DB) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// currentVersion follows goose: only the latest row of a version says whether
+// it is applied, so a migration that was rolled back does not count.
+const currentVersion = `SELECT COALESCE(MAX(version_id), 0) FROM (
+    SELECT DISTINCT ON (version_id) version_id, is_applied
+    FROM goose_db_version
+    ORDER BY version_id, id DESC
+) latest WHERE is_applied`
+
+// Migrations checks that goose applied every migration up to latest.
+func Migrations(db *sql.DB, latest int64) Check {
+	return Check{
+		Name: "migrations",
+		Run: func(ctx context.Context) error {
+			var current int64
+
+			err := db.QueryRowContext(ctx, currentVersion).Scan(&current)
+			if err != nil {
+				return err
+			}
+
+			if current < latest {
+				return fmt.Errorf("database is at version %d, want %d", current, latest)
+			}
+
+			return nil
+		},
+	}
+}
+
+// Mail checks that the mail transport can deliver. It is optional: mail is
+// queued while the transport is down, so requests can still be served.
+func Mail(transport interface{ Ping() error }) Check {
+	return Check{
+		Name: "mail",
+		Run: func(context.Context) error {
+			return transport.Ping()
+		},
+		Optional: true,
+	}
+}
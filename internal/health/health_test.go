@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChecker_Run(t *testing.T) {
+	assert := assert2.New(t)
+
+	ok := Check{Name: "ok", Run: func(context.Context) error { return nil }}
+	failing := Check{Name: "failing", Run: func(context.Context) error { return errors.New("boom") }}
+	stuck := Check{Name: "stuck", Run: func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	t.Run("all up", func(t *testing.T) {
+		report := NewChecker(time.Second, ok).Run(context.Background())
+		assert.Equal(StatusUp, report.Status)
+		assert.Equal(StatusUp, report.Checks["ok"].Status)
+	})
+
+	t.Run("one failing check takes the report down", func(t *testing.T) {
+		report := NewChecker(time.Second, ok, failing).Run(context.Background())
+		assert.Equal(StatusDown, report.Status)
+		assert.Equal(StatusUp, report.Checks["ok"].Status)
+		assert.Equal(StatusDown, report.Checks["failing"].Status)
+		assert.Equal("boom", report.Checks["failing"].Error)
+	})
+
+	t.Run("a failing optional check is reported but keeps the report up", func(t *testing.T) {
+		optional := failing
+		optional.Optional = true
+
+		report := NewChecker(time.Second, ok, optional).Run(context.Background())
+		assert.Equal(StatusUp, report.Status)
+		assert.Equal(StatusDown, report.Checks["failing"].Status)
+		assert.True(report.Checks["failing"].Optional)
+	})
+
+	t.Run("checks ignoring the context time out", func(t *testing.T) {
+		start := time.Now()
+		report := NewChecker(10*time.Millisecond, stuck).Run(context.Background())
+		assert.Less(time.Since(start), 500*time.Millisecond)
+		assert.Equal(StatusDown, report.Status)
+		assert.Equal(errTimeout.Error(), report.Checks["stuck"].Error)
+	})
+
+	t.Run("no checks", func(t *testing.T) {
+		assert.Equal(StatusUp, NewChecker(time.Second).Run(context.Background()).Status)
+	})
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	assert2 "github.com/stretchr/testify/assert"
+	"poster/internal/lib/sql/sqltest"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	assert := assert2.New(t)
+
+	db := sqltest.Open(t)
+
+	_, err := db.Exec(`CREATE TABLE goose_db_version (
+		id SERIAL PRIMARY KEY,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL
+	)`)
+	assert.NoError(err)
+
+	run := Migrations(db, 2).Run
+
+	t.Run("applied up to latest", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true), (1, true), (2, true)`)
+		assert.NoError(err)
+
+		assert.NoError(run(context.Background()))
+	})
+
+	t.Run("latest rolled back", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO goose_db_version (version_id, is_applied) VALUES (2, false)`)
+		assert.NoError(err)
+
+		assert.EqualError(run(context.Background()), "database is at version 1, want 2")
+	})
+}
@@ -0,0 +1,280 @@
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Action is the outcome of screening a piece of content. Larger values are
+// stricter, so the pipeline keeps the maximum.
+type Action int
+
+const (
+	Allow Action = iota
+	Hold
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+type Verdict struct {
+	Action Action
+	Reason string
+}
+
+// Content is a post or comment about to be stored. ExcludeID names the
+// record being edited so it is not compared with itself.
+type Content struct {
+	AuthorID  uuid.UUID
+	ExcludeID uuid.UUID
+	Text      string
+}
+
+type Filter interface {
+	Check(ctx context.Context, c Content) (Verdict, error)
+}
+
+// Pipeline runs filters in order and returns the strictest verdict. It stops
+// at the first rejection.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+func (p *Pipeline) Check(ctx context.Context, c Content) (Verdict, error) {
+	result := Verdict{Action: Allow}
+
+	if p == nil {
+		return result, nil
+	}
+
+	for _, f := range p.filters {
+		v, err := f.Check(ctx, c)
+
+		if err != nil {
+			return Verdict{}, err
+		}
+
+		if v.Action > result.Action {
+			result = v
+		}
+
+		if result.Action == Reject {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+// Tokens lowercases text, undoes common character substitutions and splits it
+// into words. Runs of single letters are glued back together, so "s p a m"
+// and "s.p.a.m" both yield "spam".
+func Tokens(text string) []string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(text) {
+		if sub, ok := leet[r]; ok {
+			r = sub
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+
+	var tokens []string
+	var glued strings.Builder
+
+	flush := func() {
+		if glued.Len() > 0 {
+			tokens = append(tokens, glued.String())
+			glued.Reset()
+		}
+	}
+
+	for _, word := range strings.Fields(b.String()) {
+		if len([]rune(word)) == 1 {
+			glued.WriteString(word)
+			continue
+		}
+
+		flush()
+		tokens = append(tokens, word)
+	}
+
+	flush()
+
+	return tokens
+}
+
+// Fingerprint collapses whitespace and case so that trivially reformatted
+// copies of a text compare equal. The duplicate lookup has to normalize
+// stored content the same way.
+func Fingerprint(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+type phrase struct {
+	tokens []string
+	action Action
+}
+
+// BannedWords matches words and phrases on token boundaries after
+// normalization, so "spammer" does not trip on "spam".
+type BannedWords struct {
+	phrases []phrase
+}
+
+// NewBannedWords builds a filter that rejects content containing any of
+// rejected and holds content containing any of held.
+func NewBannedWords(rejected, held []string) *BannedWords {
+	f := &BannedWords{}
+	f.add(rejected, Reject)
+	f.add(held, Hold)
+
+	return f
+}
+
+func (f *BannedWords) add(words []string, action Action) {
+	for _, w := range words {
+		if tokens := Tokens(w); len(tokens) > 0 {
+			f.phrases = append(f.phrases, phrase{tokens: tokens, action: action})
+		}
+	}
+}
+
+func (f *BannedWords) Check(_ context.Context, c Content) (Verdict, error) {
+	tokens := Tokens(c.Text)
+	result := Verdict{Action: Allow}
+
+	for _, p := range f.phrases {
+		if p.action <= result.Action || !containsSeq(tokens, p.tokens) {
+			continue
+		}
+
+		result = Verdict{Action: p.action, Reason: "contains banned word"}
+	}
+
+	return result, nil
+}
+
+func containsSeq(tokens, seq []string) bool {
+	for i := 0; i+len(seq) <= len(tokens); i++ {
+		match := true
+
+		for j := range seq {
+			if tokens[i+j] != seq[j] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// CountLinks returns the number of URLs in text.
+func CountLinks(text string) int {
+	return len(linkRe.FindAllStringIndex(text, -1))
+}
+
+// AccountCreated returns when the author's account was registered.
+type AccountCreated func(ctx context.Context, userID uuid.UUID) (time.Time, error)
+
+// LinkLimit holds content from accounts younger than NewAccountAge that
+// contains more than Max links.
+type LinkLimit struct {
+	Max           int
+	NewAccountAge time.Duration
+	Created       AccountCreated
+}
+
+func (f *LinkLimit) Check(ctx context.Context, c Content) (Verdict, error) {
+	if CountLinks(c.Text) <= f.Max {
+		return Verdict{Action: Allow}, nil
+	}
+
+	created, err := f.Created(ctx, c.AuthorID)
+
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	if time.Since(created) >= f.NewAccountAge {
+		return Verdict{Action: Allow}, nil
+	}
+
+	return Verdict{Action: Hold, Reason: "too many links for a new account"}, nil
+}
+
+// DuplicateCounter returns how many posts and comments by authorID other than
+// excludeID created after since have the given fingerprint.
+type DuplicateCounter func(ctx context.Context, authorID uuid.UUID, fingerprint string, since time.Time, excludeID uuid.UUID) (int64, error)
+
+// Duplicates rejects content that repeats something its author posted within
+// Window. Other users saying the same thing is not flooding. Texts shorter
+// than MinLength characters ("thanks!", "+1") are skipped.
+type Duplicates struct {
+	Window    time.Duration
+	MinLength int
+	Count     DuplicateCounter
+}
+
+func (f *Duplicates) Check(ctx context.Context, c Content) (Verdict, error) {
+	fingerprint := Fingerprint(c.Text)
+
+	if len([]rune(fingerprint)) < f.MinLength {
+		return Verdict{Action: Allow}, nil
+	}
+
+	n, err := f.Count(ctx, c.AuthorID, fingerprint, time.Now().Add(-f.Window), c.ExcludeID)
+
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	if n > 0 {
+		return Verdict{Action: Reject, Reason: "duplicate of recently posted content"}, nil
+	}
+
+	return Verdict{Action: Allow}, nil
+}
@@ -0,0 +1,173 @@
+package contentfilter
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fixed Verdict
+
+func (f fixed) Check(context.Context, Content) (Verdict, error) {
+	return Verdict(f), nil
+}
+
+type failing struct{}
+
+func (failing) Check(context.Context, Content) (Verdict, error) {
+	return Verdict{}, errors.New("boom")
+}
+
+func TestPipeline_Check(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+
+	t.Run("empty pipeline allows", func(t *testing.T) {
+		v, err := NewPipeline().Check(ctx, Content{Text: "hello"})
+		assert.NoError(err)
+		assert.Equal(Allow, v.Action)
+	})
+
+	t.Run("keeps strictest verdict", func(t *testing.T) {
+		p := NewPipeline(fixed{Action: Allow}, fixed{Action: Hold, Reason: "links"}, fixed{Action: Allow})
+		v, err := p.Check(ctx, Content{})
+		assert.NoError(err)
+		assert.Equal(Verdict{Action: Hold, Reason: "links"}, v)
+	})
+
+	t.Run("stops at rejection", func(t *testing.T) {
+		p := NewPipeline(fixed{Action: Reject, Reason: "spam"}, failing{})
+		v, err := p.Check(ctx, Content{})
+		assert.NoError(err)
+		assert.Equal(Reject, v.Action)
+	})
+
+	t.Run("propagates errors", func(t *testing.T) {
+		_, err := NewPipeline(failing{}).Check(ctx, Content{})
+		assert.Error(err)
+	})
+}
+
+func TestTokens(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("lowercases and splits on punctuation", func(t *testing.T) {
+		assert.Equal([]string{"buy", "cheap", "pills"}, Tokens("Buy CHEAP, pills!"))
+	})
+
+	t.Run("undoes substitutions", func(t *testing.T) {
+		assert.Equal([]string{"free", "casino"}, Tokens("fr33 c4$1n0"))
+	})
+
+	t.Run("glues spaced out letters", func(t *testing.T) {
+		assert.Equal([]string{"spam"}, Tokens("s p a m"))
+		assert.Equal([]string{"spam", "here"}, Tokens("s.p.a.m here"))
+	})
+}
+
+func TestBannedWords_Check(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+	f := NewBannedWords([]string{"casino", "cheap pills"}, []string{"crypto"})
+
+	cases := []struct {
+		name string
+		text string
+		want Action
+	}{
+		{"clean", "a nice day at the park", Allow},
+		{"rejected word", "visit our CASINO", Reject},
+		{"obfuscated word", "c.a.s.i.n.o tonight", Reject},
+		{"phrase", "get cheap   pills now", Reject},
+		{"held word", "talking about crypto", Hold},
+		{"reject wins over hold", "crypto casino", Reject},
+		{"no partial match", "cryptography and casinos", Allow},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := f.Check(ctx, Content{Text: tc.text})
+			assert.NoError(err)
+			assert.Equal(tc.want, v.Action)
+		})
+	}
+}
+
+func TestLinkLimit_Check(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+	text := "see https://a.example and www.b.example and http://c.example"
+
+	created := func(at time.Time) AccountCreated {
+		return func(context.Context, uuid.UUID) (time.Time, error) { return at, nil }
+	}
+
+	t.Run("counts links", func(t *testing.T) {
+		assert.Equal(3, CountLinks(text))
+		assert.Equal(0, CountLinks("no links here"))
+	})
+
+	t.Run("holds new accounts over the limit", func(t *testing.T) {
+		f := &LinkLimit{Max: 2, NewAccountAge: 72 * time.Hour, Created: created(time.Now().Add(-time.Hour))}
+		v, err := f.Check(ctx, Content{Text: text})
+		assert.NoError(err)
+		assert.Equal(Hold, v.Action)
+	})
+
+	t.Run("allows established accounts", func(t *testing.T) {
+		f := &LinkLimit{Max: 2, NewAccountAge: 72 * time.Hour, Created: created(time.Now().Add(-100 * time.Hour))}
+		v, err := f.Check(ctx, Content{Text: text})
+		assert.NoError(err)
+		assert.Equal(Allow, v.Action)
+	})
+
+	t.Run("skips lookup under the limit", func(t *testing.T) {
+		f := &LinkLimit{Max: 5, NewAccountAge: 72 * time.Hour}
+		v, err := f.Check(ctx, Content{Text: text})
+		assert.NoError(err)
+		assert.Equal(Allow, v.Action)
+	})
+}
+
+func TestDuplicates_Check(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+
+	var (
+		gotAuthor      uuid.UUID
+		gotFingerprint string
+	)
+	count := func(n int64) DuplicateCounter {
+		return func(_ context.Context, authorID uuid.UUID, fingerprint string, _ time.Time, _ uuid.UUID) (int64, error) {
+			gotAuthor, gotFingerprint = authorID, fingerprint
+			return n, nil
+		}
+	}
+
+	t.Run("rejects duplicates", func(t *testing.T) {
+		author := uuid.New()
+		f := &Duplicates{Window: time.Hour, MinLength: 10, Count: count(1)}
+		v, err := f.Check(ctx, Content{AuthorID: author, Text: "  Check out\n my   NEW site "})
+		assert.NoError(err)
+		assert.Equal(Reject, v.Action)
+		assert.Equal(author, gotAuthor)
+		assert.Equal("check out my new site", gotFingerprint)
+	})
+
+	t.Run("allows unique content", func(t *testing.T) {
+		f := &Duplicates{Window: time.Hour, MinLength: 10, Count: count(0)}
+		v, err := f.Check(ctx, Content{Text: "something original"})
+		assert.NoError(err)
+		assert.Equal(Allow, v.Action)
+	})
+
+	t.Run("ignores short texts", func(t *testing.T) {
+		f := &Duplicates{Window: time.Hour, MinLength: 10, Count: count(5)}
+		v, err := f.Check(ctx, Content{Text: "thanks!"})
+		assert.NoError(err)
+		assert.Equal(Allow, v.Action)
+	})
+}
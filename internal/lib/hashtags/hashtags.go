@@ -0,0 +1,105 @@
+package hashtags
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxLength  = 50
+	MaxPerPost = 10
+)
+
+// Normalize lowercases a tag name and strips a leading '#'. It reports false
+// when the result is empty, too long or contains characters other than
+// letters, digits and underscores.
+func Normalize(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+
+	if name == "" || utf8.RuneCountInString(name) > MaxLength {
+		return "", false
+	}
+
+	for _, r := range name {
+		if !isTagRune(r) {
+			return "", false
+		}
+	}
+
+	return name, true
+}
+
+// Extract returns the normalized #hashtags found in content in order of
+// first appearance. A '#' only starts a tag at the beginning of the text or
+// after a character that cannot be part of a tag, so "a#b" is ignored.
+func Extract(content string) []string {
+	var found []string
+	seen := make(map[string]struct{})
+
+	prev := ' '
+	for i, r := range content {
+		if r != '#' || isTagRune(prev) || prev == '#' {
+			prev = r
+			continue
+		}
+		prev = r
+
+		end := i + 1
+		for end < len(content) {
+			next, size := utf8.DecodeRuneInString(content[end:])
+			if !isTagRune(next) {
+				break
+			}
+			end += size
+		}
+
+		name, ok := Normalize(content[i+1 : end])
+		if !ok {
+			continue
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+
+		seen[name] = struct{}{}
+		found = append(found, name)
+	}
+
+	return found
+}
+
+// Merge combines explicitly passed tags with the ones extracted from content.
+// Invalid names are dropped, duplicates removed and the result is capped at
+// MaxPerPost, explicit tags taking precedence.
+func Merge(explicit []string, content string) []string {
+	result := make([]string, 0, len(explicit))
+	seen := make(map[string]struct{})
+
+	add := func(name string) {
+		if len(result) >= MaxPerPost {
+			return
+		}
+		if _, dup := seen[name]; dup {
+			return
+		}
+		seen[name] = struct{}{}
+		result = append(result, name)
+	}
+
+	for _, t := range explicit {
+		if name, ok := Normalize(t); ok {
+			add(name)
+		}
+	}
+
+	for _, name := range Extract(content) {
+		add(name)
+	}
+
+	return result
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
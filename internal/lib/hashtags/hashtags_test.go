@@ -0,0 +1,73 @@
+package hashtags
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("lowercases and strips hash", func(t *testing.T) {
+		name, ok := Normalize("  #GoLang ")
+		assert.True(ok)
+		assert.Equal("golang", name)
+	})
+
+	t.Run("keeps unicode letters", func(t *testing.T) {
+		name, ok := Normalize("Привет_2025")
+		assert.True(ok)
+		assert.Equal("привет_2025", name)
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		for _, in := range []string{"", "#", "two words", "dash-tag", strings.Repeat("a", MaxLength+1)} {
+			_, ok := Normalize(in)
+			assert.False(ok, "should reject %q", in)
+		}
+	})
+}
+
+func TestExtract(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("finds tags in order", func(t *testing.T) {
+		tags := Extract("#Go is fun, #golang and #sql! Also (#Postgres).")
+		assert.Equal([]string{"go", "golang", "sql", "postgres"}, tags)
+	})
+
+	t.Run("skips duplicates", func(t *testing.T) {
+		assert.Equal([]string{"go"}, Extract("#go #Go #GO"))
+	})
+
+	t.Run("ignores hashes inside words", func(t *testing.T) {
+		assert.Empty(Extract("issue#12 and C# and ##double and # alone"))
+	})
+
+	t.Run("no tags", func(t *testing.T) {
+		assert.Empty(Extract("plain text"))
+	})
+}
+
+func TestMerge(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("explicit tags first", func(t *testing.T) {
+		tags := Merge([]string{"News", "bad tag", "#go"}, "about #go and #chi")
+		assert.Equal([]string{"news", "go", "chi"}, tags)
+	})
+
+	t.Run("capped at max per post", func(t *testing.T) {
+		var explicit []string
+		for i := 0; i < MaxPerPost+5; i++ {
+			explicit = append(explicit, strings.Repeat("t", i+1))
+		}
+
+		assert.Len(Merge(explicit, "#extra"), MaxPerPost)
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		assert.Equal([]string{}, Merge(nil, ""))
+	})
+}
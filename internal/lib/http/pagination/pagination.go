@@ -0,0 +1,47 @@
+package pagination
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidLimit  = errors.New("limit must be a positive integer")
+	ErrInvalidOffset = errors.New("offset must be a non-negative integer")
+)
+
+type Page struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+// FromRequest reads the "limit" and "offset" query parameters. Missing values
+// fall back to DefaultLimit and zero, limits above MaxLimit are clamped.
+func FromRequest(r *http.Request) (Page, error) {
+	page := Page{Limit: DefaultLimit}
+	query := r.URL.Query()
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return Page{}, ErrInvalidLimit
+		}
+		page.Limit = int32(min(limit, MaxLimit))
+	}
+
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || offset < 0 {
+			return Page{}, ErrInvalidOffset
+		}
+		page.Offset = int32(offset)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,55 @@
+package pagination
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("defaults", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+
+		page, err := FromRequest(req)
+
+		assert.NoError(err)
+		assert.Equal(Page{Limit: DefaultLimit, Offset: 0}, page)
+	})
+
+	t.Run("custom values", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/posts?limit=5&offset=10", nil)
+
+		page, err := FromRequest(req)
+
+		assert.NoError(err)
+		assert.Equal(Page{Limit: 5, Offset: 10}, page)
+	})
+
+	t.Run("limit is clamped", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/posts?limit=1000", nil)
+
+		page, err := FromRequest(req)
+
+		assert.NoError(err)
+		assert.Equal(int32(MaxLimit), page.Limit)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		for _, q := range []string{"limit=0", "limit=-1", "limit=abc"} {
+			req := httptest.NewRequest(http.MethodGet, "/posts?"+q, nil)
+			_, err := FromRequest(req)
+			assert.ErrorIs(err, ErrInvalidLimit, q)
+		}
+	})
+
+	t.Run("invalid offset", func(t *testing.T) {
+		for _, q := range []string{"offset=-1", "offset=x", "offset=99999999999"} {
+			req := httptest.NewRequest(http.MethodGet, "/posts?"+q, nil)
+			_, err := FromRequest(req)
+			assert.ErrorIs(err, ErrInvalidOffset, q)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"net/http"
 	"sort"
+	"time"
 )
 
 type StatusType string
@@ -122,6 +123,31 @@ func Forbidden(msg string) ErrorResp {
 	}
 }
 
+type suspensionDetails struct {
+	Reason         string    `json:"reason"`
+	SuspendedUntil time.Time `json:"suspended_until"`
+}
+
+// Suspended is returned to accounts that are suspended or banned.
+func Suspended(reason string, until time.Time) ErrorResp {
+	return ErrorResp{
+		Status:     StatusError,
+		StatusCode: http.StatusForbidden,
+		Message:    "Your account is suspended.",
+		Details:    suspensionDetails{Reason: reason, SuspendedUntil: until},
+	}
+}
+
+// ContentRejected is returned when the content filter refuses a post or
+// comment.
+func ContentRejected(reason string) ErrorResp {
+	return ErrorResp{
+		Status:     StatusError,
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    "Content rejected: " + reason,
+	}
+}
+
 func InvalidInput(errs validator.ValidationErrors) ErrorResp {
 	var details []invalidField
 
@@ -6,6 +6,7 @@ import (
 	assertP "github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestForbidden(t *testing.T) {
@@ -195,3 +196,28 @@ func TestInvalidInput(t *testing.T) {
 		assert.Greater(len(resp.Details.([]invalidField)), 0, "There should be at least one validation error")
 	})
 }
+
+func TestSuspended(t *testing.T) {
+	assert := assertP.New(t)
+
+	t.Run("Returns Forbidden response with reason and expiry", func(t *testing.T) {
+		until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		resp := Suspended("spam", until)
+
+		assert.Equal(http.StatusForbidden, resp.StatusCode, "Status should be 403")
+		assert.Equal(StatusError, resp.Status, "Status should be error")
+		assert.Equal(suspensionDetails{Reason: "spam", SuspendedUntil: until}, resp.Details, "Should carry reason and expiry")
+	})
+}
+
+func TestContentRejected(t *testing.T) {
+	assert := assertP.New(t)
+
+	t.Run("Returns Unprocessable Entity with the reason", func(t *testing.T) {
+		resp := ContentRejected("contains banned word")
+
+		assert.Equal(http.StatusUnprocessableEntity, resp.StatusCode, "Status should be 422")
+		assert.Equal(StatusError, resp.Status, "Status should be error")
+		assert.Equal("Content rejected: contains banned word", resp.Message, "Message should carry the reason")
+	})
+}
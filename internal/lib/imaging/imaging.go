@@ -0,0 +1,494 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// MaxPixels guards against decompression bombs: images with more pixels are
+// rejected before being decoded.
+const MaxPixels = 50_000_000
+
+var (
+	ErrTooLarge    = errors.New("image is too large")
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrInvalidJPEG = errors.New("invalid jpeg")
+	ErrInvalidPNG  = errors.New("invalid png")
+	ErrInvalidGIF  = errors.New("invalid gif")
+)
+
+// Decode decodes a PNG, JPEG or GIF image, returning its format name.
+func Decode(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format != "png" && format != "jpeg" && format != "gif" {
+		return nil, "", ErrUnsupported
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return img, format, nil
+}
+
+// Encode writes img as JPEG or PNG. Metadata is never written.
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return ErrUnsupported
+	}
+}
+
+// Fit scales img down to fit into maxWidth x maxHeight keeping the aspect
+// ratio. Smaller images are only copied. Pixels are averaged over the covered
+// source area, which gives good quality for downscaling.
+func Fit(img image.Image, maxWidth, maxHeight int) *image.RGBA {
+	src := toRGBA(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := sw, sh
+	if dw > maxWidth {
+		dw, dh = maxWidth, max(1, sh*maxWidth/sw)
+	}
+	if dh > maxHeight {
+		dw, dh = max(1, sw*maxHeight/sh), maxHeight
+	}
+
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0 := y * sh / dh
+		y1 := max(y0+1, (y+1)*sh/dh)
+
+		for x := 0; x < dw; x++ {
+			x0 := x * sw / dw
+			x1 := max(x0+1, (x+1)*sw/dw)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// Orient applies an EXIF orientation (1-8) so the image is displayed upright
+// once the metadata is gone.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	return rgba
+}
+
+const (
+	markerSOI  = 0xD8
+	markerSOS  = 0xDA
+	markerAPP1 = 0xE1
+	markerAPPD = 0xED
+	markerCOM  = 0xFE
+)
+
+type segment struct {
+	marker byte
+	data   []byte // segment payload without marker and length
+	raw    []byte // the whole segment as found in the file
+}
+
+// segments walks the JPEG header up to the start of scan and returns the
+// header segments and the remaining bytes.
+func segments(data []byte) ([]segment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, ErrInvalidJPEG
+	}
+
+	var segs []segment
+	pos := 2
+
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, ErrInvalidJPEG
+		}
+
+		// Skip fill bytes.
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++
+		}
+
+		if pos+1 >= len(data) {
+			return nil, nil, ErrInvalidJPEG
+		}
+
+		marker := data[pos+1]
+
+		if marker == markerSOS {
+			return segs, data[pos:], nil
+		}
+
+		// Markers without a payload.
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segs = append(segs, segment{marker: marker, raw: data[pos : pos+2]})
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, nil, ErrInvalidJPEG
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+
+		if length < 2 || end > len(data) {
+			return nil, nil, ErrInvalidJPEG
+		}
+
+		segs = append(segs, segment{marker: marker, data: data[pos+4 : end], raw: data[pos:end]})
+		pos = end
+	}
+
+	return nil, nil, ErrInvalidJPEG
+}
+
+// StripJPEGMetadata removes EXIF/XMP (APP1), IPTC (APP13) and comment
+// segments without re-encoding the image data.
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	segs, rest, err := segments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, markerSOI)
+
+	for _, s := range segs {
+		if s.marker == markerAPP1 || s.marker == markerAPPD || s.marker == markerCOM {
+			continue
+		}
+		out = append(out, s.raw...)
+	}
+
+	return append(out, rest...), nil
+}
+
+// Orientation returns the EXIF orientation of a JPEG, 1 when it is missing.
+func Orientation(data []byte) int {
+	segs, _, err := segments(data)
+	if err != nil {
+		return 1
+	}
+
+	for _, s := range segs {
+		if s.marker != markerAPP1 || !bytes.HasPrefix(s.data, []byte("Exif\x00\x00")) {
+			continue
+		}
+
+		if o := tiffOrientation(s.data[6:]); o != 0 {
+			return o
+		}
+	}
+
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// StripMetadata removes metadata from a JPEG, PNG or GIF without re-encoding
+// the image data. format is the name Decode returned.
+func StripMetadata(data []byte, format string) ([]byte, error) {
+	switch format {
+	case "jpeg":
+		return StripJPEGMetadata(data)
+	case "png":
+		return StripPNGMetadata(data)
+	case "gif":
+		return StripGIFMetadata(data)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngMetadata are the ancillary chunks that carry EXIF, text or timestamps.
+var pngMetadata = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+// StripPNGMetadata removes EXIF, text and modification time chunks.
+func StripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, ErrInvalidPNG
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, ErrInvalidPNG
+		}
+
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		// Length, type, payload and CRC.
+		end := pos + 12 + length
+
+		if length < 0 || end > len(data) {
+			return nil, ErrInvalidPNG
+		}
+
+		if !pngMetadata[typ] {
+			out = append(out, data[pos:end]...)
+		}
+
+		pos = end
+
+		if typ == "IEND" {
+			return out, nil
+		}
+	}
+
+	return nil, ErrInvalidPNG
+}
+
+const (
+	gifExtension   = 0x21
+	gifImage       = 0x2C
+	gifTrailer     = 0x3B
+	gifComment     = 0xFE
+	gifApplication = 0xFF
+)
+
+// StripGIFMetadata removes comment and application extensions. The NETSCAPE
+// loop extension is kept, without it animations play only once.
+func StripGIFMetadata(data []byte) ([]byte, error) {
+	// Header and logical screen descriptor.
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrInvalidGIF
+	}
+
+	pos := 13 + colorTableSize(data[10])
+
+	if pos > len(data) {
+		return nil, ErrInvalidGIF
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:pos]...)
+
+	for pos < len(data) {
+		start := pos
+
+		switch data[pos] {
+		case gifTrailer:
+			return append(out, data[pos]), nil
+		case gifExtension:
+			if pos+2 > len(data) {
+				return nil, ErrInvalidGIF
+			}
+
+			label := data[pos+1]
+			end, err := gifSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+
+			pos = end
+
+			if label == gifComment || (label == gifApplication && !isLoopExtension(data[start+2:end])) {
+				continue
+			}
+		case gifImage:
+			if pos+10 > len(data) {
+				return nil, ErrInvalidGIF
+			}
+
+			// Descriptor, local color table and LZW minimum code size.
+			pos += 10 + colorTableSize(data[pos+9]) + 1
+
+			end, err := gifSubBlocks(data, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			pos = end
+		default:
+			return nil, ErrInvalidGIF
+		}
+
+		out = append(out, data[start:pos]...)
+	}
+
+	return nil, ErrInvalidGIF
+}
+
+// colorTableSize returns the size of the color table a GIF descriptor with
+// the packed fields flags announces.
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+
+	return 3 << (flags&0x07 + 1)
+}
+
+// gifSubBlocks skips the data sub-blocks starting at pos and returns the
+// position after the block terminator.
+func gifSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, ErrInvalidGIF
+		}
+
+		size := int(data[pos])
+		pos += 1 + size
+
+		if size == 0 {
+			return pos, nil
+		}
+	}
+}
+
+// isLoopExtension reports whether the sub-blocks of an application extension
+// belong to the NETSCAPE2.0 (or ANIMEXTS1.0) loop extension.
+func isLoopExtension(blocks []byte) bool {
+	if len(blocks) < 12 || blocks[0] != 11 {
+		return false
+	}
+
+	id := string(blocks[1:12])
+
+	return id == "NETSCAPE2.0" || id == "ANIMEXTS1.0"
+}
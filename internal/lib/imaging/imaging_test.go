@@ -0,0 +1,236 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	assert2 "github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// exifSegment builds an APP1 segment holding only an orientation tag.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, markerAPP1}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+
+	return append(seg, payload...)
+}
+
+func jpegWithExif(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	withExif := append([]byte{0xFF, markerSOI}, exifSegment(orientation)...)
+	withExif = append(withExif, 0xFF, markerCOM, 0x00, 0x07, 's', 'e', 'c', 'r', 'e')
+
+	return append(withExif, data[2:]...)
+}
+
+func TestFit(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("keeps aspect ratio", func(t *testing.T) {
+		out := Fit(testImage(400, 200), 100, 100)
+		assert.Equal(100, out.Bounds().Dx())
+		assert.Equal(50, out.Bounds().Dy())
+	})
+
+	t.Run("limited by height", func(t *testing.T) {
+		out := Fit(testImage(200, 400), 100, 100)
+		assert.Equal(50, out.Bounds().Dx())
+		assert.Equal(100, out.Bounds().Dy())
+	})
+
+	t.Run("does not upscale", func(t *testing.T) {
+		out := Fit(testImage(30, 20), 100, 100)
+		assert.Equal(30, out.Bounds().Dx())
+		assert.Equal(20, out.Bounds().Dy())
+	})
+
+	t.Run("averages pixels", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+		src.Set(0, 0, color.RGBA{A: 255})
+		src.Set(1, 0, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+
+		out := Fit(src, 1, 1)
+		assert.Equal(color.RGBA{R: 100, G: 50, B: 25, A: 255}, out.RGBAAt(0, 0))
+	})
+}
+
+func TestOrient(t *testing.T) {
+	assert := assert2.New(t)
+
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red := color.RGBA{R: 255, A: 255}
+	src.Set(0, 0, red)
+
+	t.Run("rotate 90 clockwise", func(t *testing.T) {
+		out := Orient(src, 6).(*image.RGBA)
+		assert.Equal(image.Rect(0, 0, 1, 2), out.Bounds())
+		assert.Equal(red, out.RGBAAt(0, 0))
+	})
+
+	t.Run("rotate 180", func(t *testing.T) {
+		out := Orient(src, 3).(*image.RGBA)
+		assert.Equal(red, out.RGBAAt(1, 0))
+	})
+
+	t.Run("rotate 90 counter clockwise", func(t *testing.T) {
+		out := Orient(src, 8).(*image.RGBA)
+		assert.Equal(image.Rect(0, 0, 1, 2), out.Bounds())
+		assert.Equal(red, out.RGBAAt(0, 1))
+	})
+
+	t.Run("normal orientation is untouched", func(t *testing.T) {
+		assert.Equal(image.Image(src), Orient(src, 1))
+	})
+}
+
+func TestJPEGMetadata(t *testing.T) {
+	assert := assert2.New(t)
+	data := jpegWithExif(t, 6)
+
+	t.Run("reads orientation", func(t *testing.T) {
+		assert.Equal(6, Orientation(data))
+	})
+
+	t.Run("strips exif and comments", func(t *testing.T) {
+		stripped, err := StripJPEGMetadata(data)
+		assert.NoError(err)
+		assert.False(bytes.Contains(stripped, []byte("Exif")))
+		assert.False(bytes.Contains(stripped, []byte("secre")))
+		assert.Equal(1, Orientation(stripped))
+
+		img, format, err := Decode(stripped)
+		assert.NoError(err)
+		assert.Equal("jpeg", format)
+		assert.Equal(40, img.Bounds().Dx())
+	})
+
+	t.Run("rejects non jpeg", func(t *testing.T) {
+		_, err := StripJPEGMetadata([]byte("not a jpeg"))
+		assert.ErrorIs(err, ErrInvalidJPEG)
+		assert.Equal(1, Orientation([]byte("not a jpeg")))
+	})
+}
+
+// pngChunk builds a chunk; the CRC is not checked when stripping.
+func pngChunk(typ, payload string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, payload...)
+
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func TestPNGMetadata(t *testing.T) {
+	assert := assert2.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, testImage(4, 4)))
+
+	// Metadata goes right after IHDR, which is 25 bytes long.
+	encoded := buf.Bytes()
+	header := len(pngSignature) + 25
+	data := append([]byte{}, encoded[:header]...)
+	data = append(data, pngChunk("tEXt", "Comment\x00secret")...)
+	data = append(data, pngChunk("eXIf", "MM\x00\x2a")...)
+	data = append(data, pngChunk("tIME", "\x07\xea\x01\x01\x00\x00\x00")...)
+	data = append(data, encoded[header:]...)
+
+	t.Run("strips text, exif and time", func(t *testing.T) {
+		stripped, err := StripMetadata(data, "png")
+		assert.NoError(err)
+		assert.Equal(encoded, stripped)
+	})
+
+	t.Run("rejects truncated png", func(t *testing.T) {
+		_, err := StripPNGMetadata(data[:header+4])
+		assert.ErrorIs(err, ErrInvalidPNG)
+	})
+}
+
+// gifExtensionBlock builds an extension holding payload in one sub-block.
+func gifExtensionBlock(label byte, payload string) []byte {
+	return append(append([]byte{gifExtension, label, byte(len(payload))}, payload...), 0)
+}
+
+func TestGIFMetadata(t *testing.T) {
+	assert := assert2.New(t)
+
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+
+	var buf bytes.Buffer
+	assert.NoError(gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{0, 0}}))
+
+	// The encoder writes the loop extension right after the global color table.
+	encoded := buf.Bytes()
+	header := 13 + colorTableSize(encoded[10])
+	assert.True(isLoopExtension(encoded[header+2:]))
+
+	data := append([]byte{}, encoded[:header]...)
+	data = append(data, gifExtensionBlock(gifComment, "secret")...)
+	data = append(data, gifExtensionBlock(gifApplication, "XMP DataXMPsecret")...)
+	data = append(data, encoded[header:]...)
+
+	t.Run("strips comments and application data but keeps looping", func(t *testing.T) {
+		stripped, err := StripMetadata(data, "gif")
+		assert.NoError(err)
+		assert.Equal(encoded, stripped)
+
+		g, err := gif.DecodeAll(bytes.NewReader(stripped))
+		assert.NoError(err)
+		assert.Len(g.Image, 2)
+		assert.Equal(0, g.LoopCount)
+	})
+
+	t.Run("rejects truncated gif", func(t *testing.T) {
+		_, err := StripGIFMetadata(data[:len(data)-1])
+		assert.ErrorIs(err, ErrInvalidGIF)
+	})
+}
+
+func TestDecode(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("png", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(png.Encode(&buf, testImage(3, 3)))
+
+		_, format, err := Decode(buf.Bytes())
+		assert.NoError(err)
+		assert.Equal("png", format)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, _, err := Decode([]byte("garbage"))
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,233 @@
+package dsn
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Report types, taken from the report-type parameter of multipart/report.
+const (
+	TypeDeliveryStatus = "delivery-status" // RFC 3464 bounce
+	TypeFeedback       = "feedback-report" // RFC 5965 (ARF) complaint
+)
+
+var ErrNotReport = errors.New("message is not a delivery status or feedback report")
+
+// Report is a parsed bounce or complaint.
+type Report struct {
+	Type string
+	// FeedbackType is set for feedback reports, "abuse" for spam complaints.
+	FeedbackType string
+	Recipients   []Recipient
+}
+
+// Recipient is one per-recipient section of a delivery status notification.
+// Feedback reports carry only the Email.
+type Recipient struct {
+	Email      string
+	Action     string
+	Status     string
+	Diagnostic string
+}
+
+// Bounced reports whether delivery to the recipient failed permanently.
+// Transient failures (4.x.x) are retried by the sending server and are not
+// a reason to stop mailing the address.
+func (r Recipient) Bounced() bool {
+	return r.Action == "failed" && strings.HasPrefix(r.Status, "5")
+}
+
+// Complaint reports whether a feedback report is a spam complaint.
+func (r *Report) Complaint() bool {
+	return r.Type == TypeFeedback && strings.EqualFold(r.FeedbackType, "abuse")
+}
+
+// Parse parses a complete multipart/report message as sent back by mail
+// servers and feedback loops.
+func Parse(msg io.Reader) (*Report, error) {
+	m, err := mail.ReadMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	report := &Report{Type: strings.ToLower(params["report-type"])}
+	var original []string
+
+	parts := multipart.NewReader(m.Body, params["boundary"])
+
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			status, err := ParseStatus(part)
+			if err != nil {
+				return nil, err
+			}
+			report.Recipients = status.Recipients
+		case "message/feedback-report":
+			feedback, err := parseFeedback(part)
+			if err != nil {
+				return nil, err
+			}
+			report.FeedbackType = feedback.FeedbackType
+			report.Recipients = feedback.Recipients
+		case "message/rfc822", "text/rfc822-headers":
+			original = originalRecipients(part)
+		}
+	}
+
+	// Original-Rcpt-To is optional in feedback reports; fall back to the
+	// recipients of the enclosed message.
+	if report.Type == TypeFeedback && len(report.Recipients) == 0 {
+		for _, email := range original {
+			report.Recipients = append(report.Recipients, Recipient{Email: email})
+		}
+	}
+
+	if report.Type != TypeDeliveryStatus && report.Type != TypeFeedback {
+		return nil, ErrNotReport
+	}
+
+	return report, nil
+}
+
+// ParseStatus parses the body of a message/delivery-status part: a block of
+// per-message fields followed by a block per recipient.
+func ParseStatus(body io.Reader) (*Report, error) {
+	blocks, err := readBlocks(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocks) == 0 {
+		return nil, ErrNotReport
+	}
+
+	report := &Report{Type: TypeDeliveryStatus}
+
+	for _, b := range blocks[1:] {
+		email := address(b.Get("Final-Recipient"))
+		if email == "" {
+			email = address(b.Get("Original-Recipient"))
+		}
+		if email == "" {
+			continue
+		}
+
+		status, _, _ := strings.Cut(strings.TrimSpace(b.Get("Status")), " ")
+
+		report.Recipients = append(report.Recipients, Recipient{
+			Email:      email,
+			Action:     strings.ToLower(strings.TrimSpace(b.Get("Action"))),
+			Status:     status,
+			Diagnostic: strings.TrimSpace(b.Get("Diagnostic-Code")),
+		})
+	}
+
+	return report, nil
+}
+
+func parseFeedback(body io.Reader) (*Report, error) {
+	blocks, err := readBlocks(body)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Type: TypeFeedback}
+
+	for _, b := range blocks {
+		if v := b.Get("Feedback-Type"); v != "" {
+			report.FeedbackType = strings.TrimSpace(v)
+		}
+
+		for _, v := range b.Values("Original-Rcpt-To") {
+			if email := address(v); email != "" {
+				report.Recipients = append(report.Recipients, Recipient{Email: email})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func originalRecipients(body io.Reader) []string {
+	h, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+	if err != nil && len(h) == 0 {
+		return nil
+	}
+
+	list, err := mail.ParseAddressList(h.Get("To"))
+	if err != nil {
+		return nil
+	}
+
+	emails := make([]string, 0, len(list))
+	for _, a := range list {
+		emails = append(emails, strings.ToLower(a.Address))
+	}
+
+	return emails
+}
+
+// readBlocks splits fields separated by blank lines into header blocks.
+func readBlocks(body io.Reader) ([]textproto.MIMEHeader, error) {
+	r := textproto.NewReader(bufio.NewReader(body))
+
+	var blocks []textproto.MIMEHeader
+
+	for {
+		h, err := r.ReadMIMEHeader()
+		if len(h) > 0 {
+			blocks = append(blocks, h)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// address extracts the mailbox from a field such as "rfc822; <user@host>".
+func address(field string) string {
+	if _, v, ok := strings.Cut(field, ";"); ok {
+		field = v
+	}
+
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return ""
+	}
+
+	if a, err := mail.ParseAddress(field); err == nil {
+		return strings.ToLower(a.Address)
+	}
+
+	return strings.ToLower(strings.Trim(field, "<>"))
+}
@@ -0,0 +1,107 @@
+package dsn
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const bounce = "From: MAILER-DAEMON@mx.example.com\r\n" +
+	"To: noreply@poster.local\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.com\r\n" +
+	"Arrival-Date: Mon, 19 Oct 2026 10:00:00 +0000\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; Gone@Example.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 User unknown\r\n" +
+	"\r\n" +
+	"Original-Recipient: rfc822;<busy@example.com>\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.2.2 (mailbox full)\r\n" +
+	"--b1--\r\n"
+
+const complaint = "From: feedback@isp.example\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an abuse report.\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: isp-fbl/1.0\r\n" +
+	"Version: 1\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"From: noreply@poster.local\r\n" +
+	"To: Alice <alice@example.com>\r\n" +
+	"Subject: Your daily digest\r\n" +
+	"--b2--\r\n"
+
+func TestParse(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("delivery status", func(t *testing.T) {
+		report, err := Parse(strings.NewReader(bounce))
+		assert.NoError(err)
+		assert.Equal(TypeDeliveryStatus, report.Type)
+		assert.Equal([]Recipient{
+			{Email: "gone@example.com", Action: "failed", Status: "5.1.1", Diagnostic: "smtp; 550 5.1.1 User unknown"},
+			{Email: "busy@example.com", Action: "delayed", Status: "4.2.2"},
+		}, report.Recipients)
+		assert.True(report.Recipients[0].Bounced())
+		assert.False(report.Recipients[1].Bounced())
+		assert.False(report.Complaint())
+	})
+
+	t.Run("feedback report falls back to original recipients", func(t *testing.T) {
+		report, err := Parse(strings.NewReader(complaint))
+		assert.NoError(err)
+		assert.True(report.Complaint())
+		assert.Equal([]Recipient{{Email: "alice@example.com"}}, report.Recipients)
+	})
+
+	t.Run("feedback report with original rcpt to", func(t *testing.T) {
+		msg := strings.Replace(complaint, "Version: 1\r\n", "Version: 1\r\nOriginal-Rcpt-To: <bob@example.com>\r\n", 1)
+
+		report, err := Parse(strings.NewReader(msg))
+		assert.NoError(err)
+		assert.Equal([]Recipient{{Email: "bob@example.com"}}, report.Recipients)
+	})
+
+	t.Run("not a report", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("Content-Type: text/plain\r\n\r\nhello\r\n"))
+		assert.ErrorIs(err, ErrNotReport)
+	})
+}
+
+func TestParseStatus(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("bare status without trailing newline", func(t *testing.T) {
+		report, err := ParseStatus(strings.NewReader("Reporting-MTA: dns; mx\n\nFinal-Recipient: rfc822; a@example.com\nAction: failed\nStatus: 5.0.0"))
+		assert.NoError(err)
+		assert.Equal([]Recipient{{Email: "a@example.com", Action: "failed", Status: "5.0.0"}}, report.Recipients)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := ParseStatus(strings.NewReader(""))
+		assert.ErrorIs(err, ErrNotReport)
+	})
+}
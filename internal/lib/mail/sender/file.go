@@ -0,0 +1,61 @@
+package sender
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSink writes every message into its own .eml file in a directory
+// instead of sending it, for local development.
+type FileSink struct {
+	email string
+	dir   string
+}
+
+func NewFileSink(email, dir string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create mail directory: %w", err)
+	}
+
+	return &FileSink{email: email, dir: dir}, nil
+}
+
+func (f *FileSink) Send(m *gomail.Message) error {
+	to, msg, err := Render(f.email, m)
+	if err != nil {
+		return err
+	}
+
+	return f.Deliver(to, msg)
+}
+
+// Deliver writes msg to a file named after the current time, so a directory
+// listing shows the messages in the order they were sent. The envelope
+// recipients are not part of the message, so Bcc addresses are lost.
+func (f *FileSink) Deliver(_ []string, msg []byte) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), uuid.NewString()[:8])
+
+	return os.WriteFile(filepath.Join(f.dir, name), msg, 0o644)
+}
+
+// Ping checks that the directory still exists.
+func (f *FileSink) Ping() error {
+	info, err := os.Stat(f.dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", f.dir)
+	}
+
+	return nil
+}
+
+func (f *FileSink) Close() error {
+	return nil
+}
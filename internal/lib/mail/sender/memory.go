@@ -0,0 +1,67 @@
+package sender
+
+import (
+	"gopkg.in/gomail.v2"
+	"sync"
+)
+
+// Delivered is a message recorded by Memory.
+type Delivered struct {
+	To      []string
+	Message []byte
+}
+
+// Memory keeps messages in memory instead of sending them. It is meant for
+// tests, which can inspect what would have been sent.
+type Memory struct {
+	email string
+
+	mu        sync.Mutex
+	delivered []Delivered
+}
+
+func NewMemory(email string) *Memory {
+	return &Memory{email: email}
+}
+
+func (m *Memory) Send(msg *gomail.Message) error {
+	to, raw, err := Render(m.email, msg)
+	if err != nil {
+		return err
+	}
+
+	return m.Deliver(to, raw)
+}
+
+func (m *Memory) Deliver(to []string, msg []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delivered = append(m.delivered, Delivered{To: to, Message: msg})
+
+	return nil
+}
+
+// Messages returns the messages delivered so far.
+func (m *Memory) Messages() []Delivered {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Delivered(nil), m.delivered...)
+}
+
+// Reset forgets the delivered messages.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delivered = nil
+}
+
+func (m *Memory) Ping() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,173 @@
+package sender
+
+import (
+	"errors"
+	"gopkg.in/gomail.v2"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+var errPoolClosed = errors.New("smtp pool is closed")
+
+// SMTPPool sends over up to size persistent SMTP connections instead of
+// dialing for every message. Connections idle for longer than idleTimeout
+// are closed, as servers drop them anyway.
+type SMTPPool struct {
+	email       string
+	dialer      *gomail.Dialer
+	idleTimeout time.Duration
+
+	slots  chan struct{}
+	mu     sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+type smtpConn struct {
+	sc       gomail.SendCloser
+	lastUsed time.Time
+}
+
+func NewSMTPPool(email string, dialer *gomail.Dialer, size int, idleTimeout time.Duration) *SMTPPool {
+	return &SMTPPool{
+		email:       email,
+		dialer:      dialer,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+func (p *SMTPPool) Send(m *gomail.Message) error {
+	to, msg, err := Render(p.email, m)
+	if err != nil {
+		return err
+	}
+
+	return p.Deliver(to, msg)
+}
+
+// Deliver sends msg over a pooled connection. A reused connection that
+// fails with anything but a server reply is assumed to have gone stale and
+// the message is retried once over a new one.
+func (p *SMTPPool) Deliver(to []string, msg []byte) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	c, reused, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = c.sc.Send(p.email, to, rawMessage(msg))
+
+	var protoErr *textproto.Error
+	if err != nil && reused && !errors.As(err, &protoErr) {
+		_ = c.sc.Close()
+
+		if c, err = p.dial(); err != nil {
+			return err
+		}
+
+		err = c.sc.Send(p.email, to, rawMessage(msg))
+	}
+
+	if err != nil {
+		_ = c.sc.Close()
+		return err
+	}
+
+	c.lastUsed = time.Now()
+	p.put(c)
+
+	return nil
+}
+
+// Ping checks that the server accepts connections. A fresh idle connection
+// or a busy pool counts as proof; otherwise a connection is dialed and kept
+// for the next message.
+func (p *SMTPPool) Ping() error {
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	default:
+		return nil
+	}
+
+	c, _, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	p.put(c)
+
+	return nil
+}
+
+// Close closes the idle connections. Messages sent afterwards fail.
+func (p *SMTPPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	var errs []error
+	for _, c := range p.idle {
+		errs = append(errs, c.sc.Close())
+	}
+	p.idle = nil
+
+	return errors.Join(errs...)
+}
+
+func (p *SMTPPool) get() (*smtpConn, bool, error) {
+	p.mu.Lock()
+
+	if p.closed {
+		p.mu.Unlock()
+		return nil, false, errPoolClosed
+	}
+
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if time.Since(c.lastUsed) < p.idleTimeout {
+			p.mu.Unlock()
+			return c, true, nil
+		}
+
+		_ = c.sc.Close()
+	}
+
+	p.mu.Unlock()
+
+	c, err := p.dial()
+
+	return c, false, err
+}
+
+func (p *SMTPPool) put(c *smtpConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		_ = c.sc.Close()
+		return
+	}
+
+	p.idle = append(p.idle, c)
+}
+
+func (p *SMTPPool) dial() (*smtpConn, error) {
+	if p.dialer == nil {
+		return nil, errors.New("dialer is not initialized")
+	}
+
+	sc, err := p.dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	return &smtpConn{sc: sc, lastUsed: time.Now()}, nil
+}
@@ -1,14 +1,29 @@
 package sender
 
 import (
+	"bytes"
 	"errors"
 	"gopkg.in/gomail.v2"
+	"io"
+	"net/mail"
 )
 
+var ErrNoRecipients = errors.New("message has no recipients")
+
 type MailSender interface {
 	Send(m *gomail.Message) error
 }
 
+// Transport delivers messages. Send renders a message itself, Deliver takes
+// one that was rendered before, e.g. by the mail queue. Ping reports whether
+// the transport can currently deliver.
+type Transport interface {
+	MailSender
+	Deliver(to []string, msg []byte) error
+	Ping() error
+	Close() error
+}
+
 type Sender struct {
 	Email  string
 	Dialer *gomail.Dialer
@@ -29,9 +44,99 @@ func (s *Sender) Send(m *gomail.Message) error {
 	return s.Dialer.DialAndSend(m)
 }
 
+// Deliver sends an already rendered message to the given addresses.
+func (s *Sender) Deliver(to []string, msg []byte) error {
+	if s.Email == "" {
+		return errors.New("sender email is empty")
+	}
+	if s.Dialer == nil {
+		return errors.New("dialer is not initialized")
+	}
+
+	sc, err := s.Dialer.Dial()
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	return sc.Send(s.Email, to, rawMessage(msg))
+}
+
+// Ping dials the server and hangs up.
+func (s *Sender) Ping() error {
+	if s.Dialer == nil {
+		return errors.New("dialer is not initialized")
+	}
+
+	sc, err := s.Dialer.Dial()
+	if err != nil {
+		return err
+	}
+
+	return sc.Close()
+}
+
+func (s *Sender) Close() error {
+	return nil
+}
+
 func NewSender(email string, dialer *gomail.Dialer) *Sender {
 	return &Sender{
 		Email:  email,
 		Dialer: dialer,
 	}
 }
+
+// Render sets the From header of m and returns its recipients and the
+// message as it goes over the wire.
+func Render(from string, m *gomail.Message) ([]string, []byte, error) {
+	if m == nil {
+		return nil, nil, errors.New("message cannot be nil")
+	}
+	if from == "" {
+		return nil, nil, errors.New("sender email is empty")
+	}
+
+	m.SetHeader("From", from)
+
+	to, err := Recipients(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err = m.WriteTo(&buf); err != nil {
+		return nil, nil, err
+	}
+
+	return to, buf.Bytes(), nil
+}
+
+// Recipients returns the bare addresses of the To, Cc and Bcc headers of m.
+func Recipients(m *gomail.Message) ([]string, error) {
+	var to []string
+
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		for _, value := range m.GetHeader(field) {
+			addr, err := mail.ParseAddress(value)
+			if err != nil {
+				return nil, err
+			}
+
+			to = append(to, addr.Address)
+		}
+	}
+
+	if len(to) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	return to, nil
+}
+
+type rawMessage []byte
+
+func (m rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m)
+	return int64(n), err
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	assert2 "github.com/stretchr/testify/assert"
 	"gopkg.in/gomail.v2"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -85,3 +87,95 @@ func TestNewSender(t *testing.T) {
 	})
 
 }
+
+func TestRecipients(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("collects to, cc and bcc", func(t *testing.T) {
+		m := gomail.NewMessage()
+		m.SetHeader("To", "a@example.com", "Bob <b@example.com>")
+		m.SetHeader("Cc", "c@example.com")
+		m.SetHeader("Bcc", "d@example.com")
+
+		to, err := Recipients(m)
+		assert.NoError(err)
+		assert.Equal([]string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}, to)
+	})
+
+	t.Run("no recipients", func(t *testing.T) {
+		_, err := Recipients(gomail.NewMessage())
+		assert.ErrorIs(err, ErrNoRecipients)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		m := gomail.NewMessage()
+		m.SetHeader("To", "not an address")
+
+		_, err := Recipients(m)
+		assert.Error(err)
+	})
+}
+
+func testMessage() *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("To", "test@example.com")
+	m.SetHeader("Bcc", "hidden@example.com")
+	m.SetHeader("Subject", "Test Email")
+	m.SetBody("text/plain", "This is a test email.")
+
+	return m
+}
+
+func TestFileSink(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("writes a file per message", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "mail")
+
+		sink, err := NewFileSink("from@example.com", dir)
+		assert.NoError(err)
+
+		assert.NoError(sink.Send(testMessage()))
+		assert.NoError(sink.Send(testMessage()))
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		assert.NoError(err)
+		assert.Len(files, 2)
+
+		data, err := os.ReadFile(files[0])
+		assert.NoError(err)
+		assert.Contains(string(data), "From: from@example.com")
+		assert.Contains(string(data), "Subject: Test Email")
+	})
+
+	t.Run("no recipients", func(t *testing.T) {
+		sink, err := NewFileSink("from@example.com", t.TempDir())
+		assert.NoError(err)
+
+		assert.ErrorIs(sink.Send(gomail.NewMessage()), ErrNoRecipients)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("records messages", func(t *testing.T) {
+		mem := NewMemory("from@example.com")
+
+		assert.NoError(mem.Send(testMessage()))
+
+		sent := mem.Messages()
+		assert.Len(sent, 1)
+		assert.Equal([]string{"test@example.com", "hidden@example.com"}, sent[0].To)
+		assert.Contains(string(sent[0].Message), "This is a test email.")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		mem := NewMemory("from@example.com")
+
+		assert.NoError(mem.Deliver([]string{"test@example.com"}, []byte("hello")))
+		mem.Reset()
+
+		assert.Empty(mem.Messages())
+	})
+}
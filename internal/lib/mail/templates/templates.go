@@ -0,0 +1,254 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"gopkg.in/gomail.v2"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Every email is a pair of files per locale: files/<locale>/<name>.html and
+// files/<locale>/<name>.txt. Both define a "content" template that is
+// rendered inside the matching base layout; the text file also defines the
+// "subject". Templates get a page, so the email's own data is .Data.
+//
+//go:embed files
+var files embed.FS
+
+// Names of the transactional emails.
+const (
+	Verification = "verification"
+	Digest       = "digest"
+)
+
+// DefaultLocale is used for users whose locale is unknown or unsupported.
+const DefaultLocale = "ru"
+
+// Locales lists the supported locales. Each of them provides every email.
+var Locales = []string{"ru", "en"}
+
+var funcs = map[string]any{
+	"sub": func(a, b int64) int64 { return a - b },
+}
+
+// Email is a rendered message.
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type page struct {
+	Locale  string
+	Subject string
+	Data    any
+}
+
+type set struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Registry holds the parsed templates of all emails and locales.
+type Registry struct {
+	sets  map[string]set
+	names []string
+}
+
+// New parses the embedded templates. It fails if a template is broken or a
+// locale is missing one of the emails.
+func New() (*Registry, error) {
+	reg := &Registry{sets: make(map[string]set)}
+	seen := make(map[string]int)
+
+	for _, locale := range Locales {
+		entries, err := fs.ReadDir(files, path.Join("files", locale))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), ".html")
+			if !ok {
+				continue
+			}
+
+			html, err := htmltemplate.New("layout.html").Funcs(funcs).ParseFS(files, "files/layout.html", path.Join("files", locale, name+".html"))
+			if err != nil {
+				return nil, err
+			}
+
+			text, err := texttemplate.New("layout.txt").Funcs(funcs).ParseFS(files, "files/layout.txt", path.Join("files", locale, name+".txt"))
+			if err != nil {
+				return nil, err
+			}
+
+			if text.Lookup("subject") == nil {
+				return nil, fmt.Errorf("email %s/%s has no subject", locale, name)
+			}
+
+			reg.sets[key(locale, name)] = set{html: html, text: text}
+			seen[name]++
+		}
+	}
+
+	for name, n := range seen {
+		if n != len(Locales) {
+			return nil, fmt.Errorf("email %q is not available in every locale", name)
+		}
+		reg.names = append(reg.names, name)
+	}
+
+	sort.Strings(reg.names)
+
+	return reg, nil
+}
+
+// Names returns the available emails.
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+// Render renders email name in locale, falling back to DefaultLocale.
+func (r *Registry) Render(name, locale string, data any) (Email, error) {
+	locale = Match(locale)
+
+	s, ok := r.sets[key(locale, name)]
+	if !ok {
+		return Email{}, fmt.Errorf("unknown email %q", name)
+	}
+
+	p := page{Locale: locale, Data: data}
+
+	var subject, text, html bytes.Buffer
+
+	if err := s.text.ExecuteTemplate(&subject, "subject", p); err != nil {
+		return Email{}, err
+	}
+
+	p.Subject = strings.TrimSpace(subject.String())
+
+	if err := s.text.Execute(&text, p); err != nil {
+		return Email{}, err
+	}
+
+	if err := s.html.Execute(&html, p); err != nil {
+		return Email{}, err
+	}
+
+	return Email{Subject: p.Subject, Text: text.String(), HTML: html.String()}, nil
+}
+
+// Message renders an email into a message to the given address, with the
+// plain text body and the HTML alternative.
+func (r *Registry) Message(to, name, locale string, data any) (*gomail.Message, error) {
+	email, err := r.Render(name, locale, data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", email.Subject)
+	m.SetBody("text/plain", email.Text)
+	m.AddAlternative("text/html", email.HTML)
+
+	return m, nil
+}
+
+// Match maps a locale such as "en-US" to a supported one, or DefaultLocale.
+func Match(locale string) string {
+	if l, ok := lookup(locale); ok {
+		return l
+	}
+
+	return DefaultLocale
+}
+
+func lookup(locale string) (string, bool) {
+	tag := strings.ToLower(strings.TrimSpace(locale))
+
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	for _, l := range Locales {
+		if l == tag {
+			return l, true
+		}
+	}
+
+	return "", false
+}
+
+// Negotiate picks the supported locale the client prefers most according to
+// an Accept-Language header, or DefaultLocale.
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+
+	var choices []choice
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		choices = append(choices, choice{tag: tag, q: q})
+	}
+
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, c := range choices {
+		if l, ok := lookup(c.tag); ok && c.q > 0 {
+			return l
+		}
+	}
+
+	return DefaultLocale
+}
+
+// Sample returns example data for an email, used by the preview endpoint.
+func Sample(name string) any {
+	switch name {
+	case Verification:
+		return map[string]any{"Code": "123456"}
+	case Digest:
+		return map[string]any{
+			"Username":  "alice",
+			"Frequency": "daily",
+			"Items": []map[string]any{
+				{"Type": "comment", "Actor": "bob", "Count": int64(3)},
+				{"Type": "mention", "Actor": "carol", "Count": int64(1)},
+				{"Type": "follow", "Actor": "dave", "Count": int64(2)},
+			},
+			"Links": []map[string]any{
+				{"Category": "replies", "URL": "http://localhost:8080/unsubscribe?token=sample"},
+				{"Category": "all", "URL": "http://localhost:8080/unsubscribe?token=sample"},
+			},
+		}
+	}
+
+	return nil
+}
+
+func key(locale, name string) string {
+	return locale + "/" + name
+}
@@ -0,0 +1,95 @@
+package templates
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegistry_Render(t *testing.T) {
+	assert := assert2.New(t)
+
+	reg, err := New()
+	assert.NoError(err)
+	assert.Equal([]string{Digest, Verification}, reg.Names())
+
+	t.Run("every email renders in every locale", func(t *testing.T) {
+		for _, name := range reg.Names() {
+			for _, locale := range Locales {
+				email, err := reg.Render(name, locale, Sample(name))
+				assert.NoError(err, "%s/%s", locale, name)
+				assert.NotEmpty(email.Subject, "%s/%s", locale, name)
+				assert.NotEmpty(email.Text, "%s/%s", locale, name)
+				assert.Contains(email.HTML, `<html lang="`+locale+`">`)
+			}
+		}
+	})
+
+	t.Run("verification", func(t *testing.T) {
+		email, err := reg.Render(Verification, "en", map[string]any{"Code": "424242"})
+		assert.NoError(err)
+		assert.Equal("🔐 Confirm your registration", email.Subject)
+		assert.Contains(email.Text, "Your verification code is: 424242")
+		assert.Contains(email.HTML, `<p class="code">424242</p>`)
+		assert.Contains(email.HTML, "<title>🔐 Confirm your registration</title>")
+	})
+
+	t.Run("unsupported locale falls back", func(t *testing.T) {
+		email, err := reg.Render(Verification, "de-DE", map[string]any{"Code": "1"})
+		assert.NoError(err)
+		assert.Equal("🔐 Подтверждение регистрации", email.Subject)
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		email, err := reg.Render(Digest, "en", Sample(Digest))
+		assert.NoError(err)
+		assert.Equal("Your daily digest: 3 new notifications", email.Subject)
+		assert.Contains(email.Text, "- bob and 2 others commented on your post")
+		assert.Contains(email.Text, "- carol mentioned you")
+		assert.Contains(email.Text, "- dave and 1 other followed you")
+		assert.Contains(email.HTML, "<li>carol mentioned you</li>")
+
+		email, err = reg.Render(Digest, "ru", Sample(Digest))
+		assert.NoError(err)
+		assert.Contains(email.Text, "- Комментарии к вашему посту от bob и ещё 2")
+		assert.Contains(email.Text, "- Новые подписчики: dave и ещё 1")
+	})
+
+	t.Run("escapes html", func(t *testing.T) {
+		email, err := reg.Render(Digest, "en", map[string]any{
+			"Username":  "<script>",
+			"Frequency": "immediate",
+			"Items":     []map[string]any{{"Type": "follow", "Actor": "<b>x</b>", "Count": int64(1)}},
+		})
+		assert.NoError(err)
+		assert.Equal("You have a new notification", email.Subject)
+		assert.NotContains(email.HTML, "<script>")
+		assert.Contains(email.HTML, "&lt;b&gt;x&lt;/b&gt; followed you")
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		_, err := reg.Render("nope", "en", nil)
+		assert.Error(err)
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	assert := assert2.New(t)
+
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", DefaultLocale},
+		{"en-US,en;q=0.9", "en"},
+		{"de-DE,de;q=0.9,en;q=0.8,ru;q=0.7", "en"},
+		{"en;q=0.5, ru;q=0.8", "ru"},
+		{"en;q=0, fr", DefaultLocale},
+		{"RU", "ru"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.header, func(t *testing.T) {
+			assert.Equal(tc.want, Negotiate(tc.header))
+		})
+	}
+}
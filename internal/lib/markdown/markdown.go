@@ -0,0 +1,419 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Render converts the supported Markdown subset into HTML. Raw HTML in the
+// source is always escaped, so the output only ever contains the allow-listed
+// tags: p, br, h1-h6, strong, em, del, code, pre, blockquote, ul, ol, li, hr
+// and a. Links are limited to http, https and mailto and get rel="nofollow".
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+
+	return b.String()
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	ruleRe      = regexp.MustCompile(`^(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	unorderedRe = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedRe   = regexp.MustCompile(`^(\d{1,9})[.)]\s+(.*)$`)
+	languageRe  = regexp.MustCompile(`^[A-Za-z0-9_+-]{1,32}$`)
+)
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			i = renderCodeBlock(b, lines, i)
+
+		case headingRe.MatchString(trimmed):
+			m := headingRe.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case ruleRe.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			i = renderQuote(b, lines, i)
+
+		case unorderedRe.MatchString(trimmed), orderedRe.MatchString(trimmed):
+			i = renderList(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i)
+		}
+	}
+}
+
+func startsBlock(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```") ||
+		strings.HasPrefix(trimmed, ">") ||
+		headingRe.MatchString(trimmed) ||
+		ruleRe.MatchString(trimmed) ||
+		unorderedRe.MatchString(trimmed) ||
+		orderedRe.MatchString(trimmed)
+}
+
+func renderCodeBlock(b *strings.Builder, lines []string, i int) int {
+	lang := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "```"))
+
+	var code []string
+	i++
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "```" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	if languageRe.MatchString(lang) {
+		b.WriteString(`<pre><code class="language-` + escape(strings.ToLower(lang)) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+
+	for _, line := range code {
+		b.WriteString(escape(line))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmed, ">") {
+			break
+		}
+		trimmed = strings.TrimPrefix(trimmed, ">")
+		inner = append(inner, strings.TrimPrefix(trimmed, " "))
+	}
+
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, inner)
+	b.WriteString("</blockquote>\n")
+
+	return i
+}
+
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first := strings.TrimSpace(lines[i])
+	ordered := !unorderedRe.MatchString(first)
+
+	if ordered {
+		start := orderedRe.FindStringSubmatch(first)[1]
+		if n, _ := strconv.Atoi(start); n != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(n) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	var items [][]string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+
+		if isItem(trimmed, ordered) {
+			items = append(items, []string{itemText(trimmed, ordered)})
+			continue
+		}
+
+		// Indented non-empty lines continue the current item, anything else
+		// ends the list.
+		if trimmed == "" || len(items) == 0 || lines[i] == trimmed || startsBlock(trimmed) {
+			break
+		}
+		items[len(items)-1] = append(items[len(items)-1], trimmed)
+	}
+
+	for _, item := range items {
+		b.WriteString("<li>" + renderLines(item) + "</li>\n")
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+
+	return i
+}
+
+func isItem(trimmed string, ordered bool) bool {
+	if ordered {
+		return orderedRe.MatchString(trimmed)
+	}
+	return unorderedRe.MatchString(trimmed)
+}
+
+func itemText(trimmed string, ordered bool) string {
+	if ordered {
+		return orderedRe.FindStringSubmatch(trimmed)[2]
+	}
+	return unorderedRe.FindStringSubmatch(trimmed)[1]
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int) int {
+	var para []string
+
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || (len(para) > 0 && startsBlock(trimmed)) {
+			break
+		}
+		para = append(para, trimmed)
+	}
+
+	b.WriteString("<p>" + renderLines(para) + "</p>\n")
+
+	return i
+}
+
+// renderLines renders consecutive lines of one block, keeping the author's
+// line breaks.
+func renderLines(lines []string) string {
+	return strings.ReplaceAll(renderInline(strings.Join(lines, "\n")), "\n", "<br>\n")
+}
+
+type delimiter struct {
+	token string
+	tag   string
+}
+
+// Longer tokens go first so "**" is not read as two "*".
+var delimiters = []delimiter{
+	{"**", "strong"},
+	{"__", "strong"},
+	{"~~", "del"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+type inlineRenderer struct {
+	src     string
+	noLinks bool
+	// unclosed remembers tokens that have no valid closer left in src, which
+	// keeps pathological input like "*a*a*a..." linear.
+	unclosed map[string]bool
+}
+
+func renderInline(src string) string {
+	r := inlineRenderer{src: src, unclosed: make(map[string]bool)}
+	return r.render()
+}
+
+func (r *inlineRenderer) render() string {
+	var b strings.Builder
+	s := r.src
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(escape(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := r.closing("`", i+1); end >= 0 {
+				b.WriteString("<code>" + escape(s[i+1:end]) + "</code>")
+				i = end + 1
+				continue
+			}
+
+		case c == '[' && !r.noLinks:
+			if next, ok := r.link(&b, i); ok {
+				i = next
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if next, ok := r.emphasis(&b, i); ok {
+				i = next
+				continue
+			}
+		}
+
+		b.WriteString(escape(s[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+func (r *inlineRenderer) emphasis(b *strings.Builder, i int) (int, bool) {
+	s := r.src
+
+	for _, d := range delimiters {
+		if !strings.HasPrefix(s[i:], d.token) {
+			continue
+		}
+
+		open := i + len(d.token)
+		if open >= len(s) || isSpace(s[open]) {
+			return 0, false
+		}
+		if d.token[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			return 0, false
+		}
+
+		end := r.closing(d.token, open)
+		if end < 0 {
+			continue
+		}
+
+		inner := inlineRenderer{src: s[open:end], noLinks: r.noLinks, unclosed: make(map[string]bool)}
+		b.WriteString("<" + d.tag + ">" + inner.render() + "</" + d.tag + ">")
+
+		return end + len(d.token), true
+	}
+
+	return 0, false
+}
+
+// closing returns the position of the first valid closer for token after
+// from, or -1. Callers scan left to right, so a failed lookup stays failed.
+func (r *inlineRenderer) closing(token string, from int) int {
+	if r.unclosed[token] {
+		return -1
+	}
+
+	s := r.src
+	for j := from; j+len(token) <= len(s); j++ {
+		if !strings.HasPrefix(s[j:], token) {
+			continue
+		}
+
+		if token == "`" {
+			if j > from {
+				return j
+			}
+			continue
+		}
+
+		if j == from || isSpace(s[j-1]) {
+			continue
+		}
+
+		after := j + len(token)
+
+		// A single delimiter must not be half of a double one.
+		if len(token) == 1 && ((after < len(s) && s[after] == token[0]) || s[j-1] == token[0]) {
+			continue
+		}
+		if token[0] == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+
+		return j
+	}
+
+	r.unclosed[token] = true
+
+	return -1
+}
+
+func (r *inlineRenderer) link(b *strings.Builder, i int) (int, bool) {
+	s := r.src
+
+	textEnd := strings.IndexByte(s[i:], ']')
+	if textEnd < 0 {
+		return 0, false
+	}
+	textEnd += i
+
+	if textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		return 0, false
+	}
+
+	urlEnd := strings.IndexByte(s[textEnd+2:], ')')
+	if urlEnd < 0 {
+		return 0, false
+	}
+	urlEnd += textEnd + 2
+
+	rawURL := strings.TrimSpace(s[textEnd+2 : urlEnd])
+	if strings.ContainsAny(rawURL, " \t\n") {
+		return 0, false
+	}
+
+	text := inlineRenderer{src: s[i+1 : textEnd], noLinks: true, unclosed: make(map[string]bool)}
+	rendered := text.render()
+
+	if href, ok := safeURL(rawURL); ok {
+		b.WriteString(`<a href="` + escape(href) + `" rel="nofollow">` + rendered + "</a>")
+	} else {
+		b.WriteString(rendered)
+	}
+
+	return urlEnd + 1, true
+}
+
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"flag"
+	assert2 "github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRenderGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".md")
+
+		t.Run(name, func(t *testing.T) {
+			assert := assert2.New(t)
+
+			src, err := os.ReadFile(file)
+			assert.NoError(err)
+
+			got := Render(string(src))
+			golden := strings.TrimSuffix(file, ".md") + ".golden.html"
+
+			if *update {
+				assert.NoError(os.WriteFile(golden, []byte(got), 0o644))
+			}
+
+			want, err := os.ReadFile(golden)
+			assert.NoError(err)
+			assert.Equal(string(want), got)
+		})
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	assert := assert2.New(t)
+
+	cases := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		"**<b>bold</b>**",
+		"`<code>`",
+		"[click](javascript:alert(1))",
+		"[click](JaVaScRiPt:alert(1))",
+		"[click](data:text/html;base64,PHNjcmlwdD4=)",
+		`[x](https://example.com/"onmouseover="alert(1))`,
+		"```\"><script>\n<script>\n```",
+	}
+
+	for _, src := range cases {
+		out := Render(src)
+		assert.NotContains(out, "<script", src)
+		assert.NotContains(out, "<img", src)
+		assert.NotContains(out, "<b>", src)
+		assert.NotContains(out, "javascript:", src)
+		assert.NotContains(out, "JaVaScRiPt:", src)
+		assert.NotContains(out, "data:", src)
+		assert.NotContains(out, `"onmouseover`, src)
+	}
+}
+
+func TestRenderLinks(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("safe link gets nofollow", func(t *testing.T) {
+		out := Render("[site](https://example.com/a?b=1&c=2)")
+		assert.Equal(`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow">site</a></p>`+"\n", out)
+	})
+
+	t.Run("unsafe link keeps only the text", func(t *testing.T) {
+		assert.Equal("<p>site</p>\n", Render("[site](ftp://example.com)"))
+	})
+}
+
+func TestRenderPathologicalInput(t *testing.T) {
+	src := strings.Repeat("*a_b`c[d](", 20000)
+
+	done := make(chan struct{})
+	go func() {
+		Render(src)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("render took too long")
+	}
+}
@@ -0,0 +1,54 @@
+package reactions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Like is the reaction behind the classic like endpoints and the like_count
+// columns. Existing likes were migrated to it, so it must stay allowed.
+const Like = "👍"
+
+const variationSelector = "\uFE0F"
+
+type AllowList struct {
+	canonical map[string]string
+	ordered   []string
+}
+
+// NewAllowList builds the list of emoji users may react with.
+func NewAllowList(emoji []string) (*AllowList, error) {
+	l := &AllowList{canonical: make(map[string]string, len(emoji))}
+
+	for _, e := range emoji {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+
+		key := strings.ReplaceAll(e, variationSelector, "")
+		if _, dup := l.canonical[key]; dup {
+			continue
+		}
+
+		l.canonical[key] = e
+		l.ordered = append(l.ordered, e)
+	}
+
+	if _, ok := l.canonical[Like]; !ok {
+		return nil, fmt.Errorf("reaction allow-list must contain %s", Like)
+	}
+
+	return l, nil
+}
+
+// Canonical returns the configured spelling of emoji. Clients often add or
+// drop the U+FE0F variation selector, both forms are accepted.
+func (l *AllowList) Canonical(emoji string) (string, bool) {
+	e, ok := l.canonical[strings.ReplaceAll(strings.TrimSpace(emoji), variationSelector, "")]
+	return e, ok
+}
+
+func (l *AllowList) All() []string {
+	return append([]string(nil), l.ordered...)
+}
@@ -0,0 +1,45 @@
+package reactions
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewAllowList(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("requires like reaction", func(t *testing.T) {
+		_, err := NewAllowList([]string{"❤️", "😂"})
+		assert.Error(err)
+	})
+
+	t.Run("skips blanks and duplicates", func(t *testing.T) {
+		l, err := NewAllowList([]string{"👍", " ", "❤️", "❤", "😂"})
+		assert.NoError(err)
+		assert.Equal([]string{"👍", "❤️", "😂"}, l.All())
+	})
+}
+
+func TestAllowList_Canonical(t *testing.T) {
+	assert := assert2.New(t)
+
+	l, err := NewAllowList([]string{"👍", "❤️"})
+	assert.NoError(err)
+
+	t.Run("exact match", func(t *testing.T) {
+		e, ok := l.Canonical("👍")
+		assert.True(ok)
+		assert.Equal("👍", e)
+	})
+
+	t.Run("variation selector is ignored", func(t *testing.T) {
+		e, ok := l.Canonical("❤")
+		assert.True(ok)
+		assert.Equal("❤️", e)
+	})
+
+	t.Run("unknown emoji", func(t *testing.T) {
+		_, ok := l.Canonical("🦄")
+		assert.False(ok)
+	})
+}
@@ -0,0 +1,29 @@
+package sqlhelpers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DBTX is the interface the sqlc generated queries run on. Instrumentation
+// wraps it to observe every query.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// QueryName returns the name of a sqlc query, taken from the
+// "-- name: GetPost :one" comment sqlc starts every query with, or "other".
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+
+	name, _, _ := strings.Cut(rest, " ")
+
+	return name
+}
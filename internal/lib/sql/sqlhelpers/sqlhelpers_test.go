@@ -71,3 +71,15 @@ func TestExtractDuplicateFields(t *testing.T) {
 		assert.Equal("unknown field", details)
 	})
 }
+
+func TestQueryName(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("sqlc query", func(t *testing.T) {
+		assert.Equal("GetPost", QueryName("-- name: GetPost :one\nSELECT * FROM posts WHERE id = $1"))
+	})
+
+	t.Run("handwritten query", func(t *testing.T) {
+		assert.Equal("other", QueryName("SELECT 1"))
+	})
+}
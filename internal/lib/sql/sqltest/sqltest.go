@@ -0,0 +1,94 @@
+// Package sqltest runs tests against a real PostgreSQL database. Tests that
+// use it are skipped unless POSTER_TEST_DATABASE_URL points at a database the
+// tests may create schemas in.
+package sqltest
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"io/fs"
+	"net/url"
+	"os"
+	"poster/sql/migrations"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const URLKey = "POSTER_TEST_DATABASE_URL"
+
+// Open migrates a fresh schema and returns a connection that uses it. The
+// schema is dropped when the test ends.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(URLKey)
+	if dsn == "" {
+		t.Skipf("%s is not set", URLKey)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// migrate applies the Up section of every migration in order.
+func migrate(db *sql.DB) error {
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			return err
+		}
+
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		up = strings.Replace(up, "-- +goose Up", "", 1)
+
+		if _, err := db.Exec(up); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
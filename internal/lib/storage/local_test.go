@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+
+	store, err := NewLocal(t.TempDir())
+	assert.NoError(err)
+
+	t.Run("put and get", func(t *testing.T) {
+		err := store.Put(ctx, "ab/abcdef.png", strings.NewReader("content"), 7, "image/png")
+		assert.NoError(err)
+
+		exists, err := store.Exists(ctx, "ab/abcdef.png")
+		assert.NoError(err)
+		assert.True(exists)
+
+		rc, err := store.Get(ctx, "ab/abcdef.png")
+		assert.NoError(err)
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		assert.NoError(err)
+		assert.Equal("content", string(data))
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		_, err := store.Get(ctx, "missing")
+		assert.ErrorIs(err, ErrNotFound)
+
+		exists, err := store.Exists(ctx, "missing")
+		assert.NoError(err)
+		assert.False(exists)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(store.Put(ctx, "to-delete", strings.NewReader("x"), 1, ""))
+		assert.NoError(store.Delete(ctx, "to-delete"))
+		assert.NoError(store.Delete(ctx, "to-delete"), "deleting twice is not an error")
+
+		exists, err := store.Exists(ctx, "to-delete")
+		assert.NoError(err)
+		assert.False(exists)
+	})
+
+	t.Run("rejects escaping keys", func(t *testing.T) {
+		for _, key := range []string{"", "../etc/passwd", "/abs", "a/../../b", "a\\b", "a//b"} {
+			err := store.Put(ctx, key, strings.NewReader("x"), 1, "")
+			assert.ErrorIs(err, ErrInvalidKey, key)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package unsubscribe
+
+import (
+	"github.com/google/uuid"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	assert := assert2.New(t)
+	s := NewSigner("secret")
+	userID := uuid.New()
+
+	t.Run("round trip", func(t *testing.T) {
+		id, category, err := s.Parse(s.Token(userID, "replies"))
+		assert.NoError(err)
+		assert.Equal(userID, id)
+		assert.Equal("replies", category)
+	})
+
+	t.Run("rejects tokens signed with another secret", func(t *testing.T) {
+		_, _, err := NewSigner("other").Parse(s.Token(userID, All))
+		assert.ErrorIs(err, ErrInvalidToken)
+	})
+
+	t.Run("rejects tampered payload", func(t *testing.T) {
+		token := s.Token(userID, "replies")
+		other := s.Token(uuid.New(), "replies")
+		forged := other[:len(other)-len(token[len(token)-43:])] + token[len(token)-43:]
+		_, _, err := s.Parse(forged)
+		assert.ErrorIs(err, ErrInvalidToken)
+	})
+
+	t.Run("rejects garbage", func(t *testing.T) {
+		for _, token := range []string{"", "abc", "a.b", "!!.??"} {
+			_, _, err := s.Parse(token)
+			assert.ErrorIs(err, ErrInvalidToken, token)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package mailqueue
+
+import (
+	"errors"
+	"fmt"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert2.New(t)
+
+	base, max := 30*time.Second, 10*time.Minute
+
+	cases := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, max},
+		{100, max},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("attempt %d", tc.attempts), func(t *testing.T) {
+			assert.Equal(tc.want, Backoff(tc.attempts, base, max))
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	assert := assert2.New(t)
+
+	assert.True(Permanent(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}))
+	assert.True(Permanent(fmt.Errorf("send: %w", &textproto.Error{Code: 554, Msg: "rejected"})))
+	assert.False(Permanent(&textproto.Error{Code: 451, Msg: "try again later"}))
+	assert.False(Permanent(errors.New("connection refused")))
+}
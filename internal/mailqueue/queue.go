@@ -0,0 +1,50 @@
+package mailqueue
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+	"poster/internal/database"
+	"poster/internal/lib/mail/sender"
+	"time"
+)
+
+// Queue is a sender.MailSender that stores messages in the outbox instead of
+// talking to the mail server, so callers never wait on SMTP. The Worker
+// delivers them later.
+type Queue struct {
+	query *database.Queries
+	from  string
+}
+
+func NewQueue(db *database.Queries, from string) *Queue {
+	return &Queue{
+		query: db,
+		from:  from,
+	}
+}
+
+// Send renders m and adds it to the outbox. Suppressed recipients are
+// dropped; if there are none left, Send fails with ErrSuppressed.
+func (q *Queue) Send(m *gomail.Message) error {
+	to, msg, err := sender.Render(q.from, m)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if to, err = deliverable(ctx, q.query, to); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	return q.query.EnqueueMail(ctx, database.EnqueueMailParams{
+		ID:            uuid.New(),
+		Recipients:    to,
+		Message:       msg,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
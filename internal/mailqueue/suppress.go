@@ -0,0 +1,64 @@
+package mailqueue
+
+import (
+	"context"
+	"errors"
+	"poster/internal/database"
+	"strings"
+	"time"
+)
+
+// Suppression reasons.
+const (
+	ReasonBounced    = "bounced"
+	ReasonComplained = "complained"
+)
+
+var ErrSuppressed = errors.New("all recipients are suppressed")
+
+// Suppress stops all mail to email, because it bounced or its owner
+// complained about it.
+func Suppress(ctx context.Context, db *database.Queries, email, reason, detail string) error {
+	return db.SuppressEmail(ctx, database.SuppressEmailParams{
+		Email:        email,
+		Reason:       reason,
+		Detail:       detail,
+		SuppressedAt: time.Now(),
+	})
+}
+
+// deliverable drops the suppressed addresses from to. It fails with
+// ErrSuppressed if none are left.
+func deliverable(ctx context.Context, db *database.Queries, to []string) ([]string, error) {
+	emails := make([]string, len(to))
+	for i, addr := range to {
+		emails[i] = strings.ToLower(addr)
+	}
+
+	suppressed, err := db.GetSuppressedEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(suppressed) == 0 {
+		return to, nil
+	}
+
+	skip := make(map[string]struct{}, len(suppressed))
+	for _, email := range suppressed {
+		skip[email] = struct{}{}
+	}
+
+	allowed := make([]string, 0, len(to))
+	for i, addr := range to {
+		if _, ok := skip[emails[i]]; !ok {
+			allowed = append(allowed, addr)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, ErrSuppressed
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,242 @@
+package mailqueue
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/textproto"
+	"poster/internal/database"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/metrics"
+	"poster/internal/tracing"
+	"sync"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+
+	// keepSent is how long delivered messages stay in the outbox.
+	keepSent = 7 * 24 * time.Hour
+)
+
+// Deliverer hands a rendered message to the mail server.
+type Deliverer interface {
+	Deliver(to []string, msg []byte) error
+}
+
+// Options tune the worker. A message that fails MaxAttempts times, or is
+// rejected permanently by the server, is moved to the dead state and left
+// for inspection.
+type Options struct {
+	Workers      int
+	BatchSize    int32
+	PollInterval time.Duration
+	MaxAttempts  int32
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+// Worker delivers queued messages with a pool of Options.Workers senders,
+// retrying failures with exponential backoff.
+type Worker struct {
+	logger    *slog.Logger
+	query     *database.Queries
+	deliverer Deliverer
+	opts      Options
+}
+
+func NewWorker(log *slog.Logger, db *database.Queries, deliverer Deliverer, opts Options) *Worker {
+	return &Worker{
+		logger:    log,
+		query:     db,
+		deliverer: deliverer,
+		opts:      opts,
+	}
+}
+
+// Run delivers due messages until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	jobs := make(chan database.MailOutbox)
+
+	// A message handed to the server must be marked sent even if ctx is
+	// cancelled meanwhile, or it would be delivered again after a restart.
+	deliverCtx := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				w.deliver(deliverCtx, m)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		w.dispatch(ctx, jobs)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			w.cleanup(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) dispatch(ctx context.Context, jobs chan<- database.MailOutbox) {
+	const op = "mailqueue.Worker.dispatch"
+
+	messages, err := w.query.ClaimDueMail(ctx, database.ClaimDueMailParams{
+		Now:       time.Now(),
+		BatchSize: w.opts.BatchSize,
+	})
+
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.ErrorContext(ctx, "Failed to claim mail", slog.String("op", op), sl.Err(err))
+		}
+		return
+	}
+
+	for _, m := range messages {
+		select {
+		case jobs <- m:
+		case <-ctx.Done():
+			// Claimed messages are picked up again once the claim expires.
+			return
+		}
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, m database.MailOutbox) {
+	const op = "mailqueue.Worker.deliver"
+
+	ctx, span := tracing.Tracer().Start(ctx, "mail.deliver", trace.WithAttributes(
+		attribute.String("mail.id", m.ID.String()),
+		attribute.Int("mail.attempt", int(m.Attempts)),
+		attribute.Int("mail.recipients", len(m.Recipients)),
+	))
+	defer span.End()
+
+	log := w.logger.With(slog.String("op", op), slog.String("mail_id", m.ID.String()), slog.Int("attempt", int(m.Attempts)))
+
+	// Addresses may have bounced since the message was queued.
+	to, err := deliverable(ctx, w.query, m.Recipients)
+
+	if err == nil {
+		err = w.deliverer.Deliver(to, m.Message)
+	}
+
+	if err == nil {
+		record(span, metrics.MailSent, nil)
+
+		if err = w.query.MarkMailSent(ctx, database.MarkMailSentParams{SentAt: time.Now(), ID: m.ID}); err != nil {
+			log.ErrorContext(ctx, "Failed to mark mail sent", sl.Err(err))
+		}
+		return
+	}
+
+	if Permanent(err) || errors.Is(err, ErrSuppressed) || m.Attempts >= w.opts.MaxAttempts {
+		outcome := metrics.MailDead
+		if errors.Is(err, ErrSuppressed) {
+			outcome = metrics.MailSuppressed
+		}
+
+		record(span, outcome, err)
+		log.ErrorContext(ctx, "Giving up on mail", sl.Err(err))
+
+		if err = w.query.MarkMailDead(ctx, database.MarkMailDeadParams{LastError: err.Error(), ID: m.ID}); err != nil {
+			log.ErrorContext(ctx, "Failed to mark mail dead", sl.Err(err))
+		}
+		return
+	}
+
+	record(span, metrics.MailRetry, err)
+
+	next := time.Now().Add(Backoff(m.Attempts, w.opts.BaseBackoff, w.opts.MaxBackoff))
+	log.WarnContext(ctx, "Failed to deliver mail, will retry", slog.Time("next_attempt_at", next), sl.Err(err))
+
+	err = w.query.RetryMail(ctx, database.RetryMailParams{
+		NextAttemptAt: next,
+		LastError:     err.Error(),
+		ID:            m.ID,
+	})
+
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to reschedule mail", sl.Err(err))
+	}
+}
+
+// record counts the outcome of a delivery and notes it on its span.
+func record(span trace.Span, outcome string, err error) {
+	metrics.MailDeliveries.WithLabelValues(outcome).Inc()
+	span.SetAttributes(attribute.String("mail.outcome", outcome))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (w *Worker) cleanup(ctx context.Context) {
+	const op = "mailqueue.Worker.cleanup"
+
+	deleted, err := w.query.DeleteSentMail(ctx, time.Now().Add(-keepSent))
+
+	if err != nil {
+		w.logger.ErrorContext(ctx, "Failed to delete sent mail", slog.String("op", op), sl.Err(err))
+		return
+	}
+
+	if deleted > 0 {
+		w.logger.InfoContext(ctx, "Deleted sent mail", slog.String("op", op), slog.Int64("count", deleted))
+	}
+}
+
+// Backoff returns the delay before retrying after the given number of
+// attempts: base, 2*base, 4*base, ... capped at max.
+func Backoff(attempts int32, base, max time.Duration) time.Duration {
+	delay := base
+
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
+
+// Permanent reports whether the mail server rejected a message for good
+// (a 5xx reply), so retrying cannot help.
+func Permanent(err error) bool {
+	var protoErr *textproto.Error
+
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
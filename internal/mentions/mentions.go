@@ -0,0 +1,204 @@
+package mentions
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"poster/internal/database"
+	"poster/internal/notify"
+	"unicode"
+)
+
+const (
+	// MaxLength matches the users.username column.
+	MaxLength = 100
+
+	// MaxPerText caps how many @mentions of one text are resolved, so a post
+	// cannot be used to ping everyone at once.
+	MaxPerText = 20
+)
+
+// Match is an @username found in a text. Offset and Length count Unicode
+// code points and include the '@'.
+type Match struct {
+	Offset   int
+	Length   int
+	Username string
+}
+
+// Span is a mention resolved to a user, as returned to clients. Its JSON
+// shape matches the mentions column of the post and comment queries.
+type Span struct {
+	Offset   int       `json:"offset"`
+	Length   int       `json:"length"`
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+}
+
+// Extract returns the @mentions in content in order of appearance. An '@'
+// only starts a mention at the beginning of the text or after a character
+// that cannot be part of a username, so e-mail addresses are ignored.
+// Trailing dots and dashes are treated as punctuation.
+func Extract(content string) []Match {
+	var found []Match
+
+	runes := []rune(content)
+
+	for i := 0; i < len(runes) && len(found) < MaxPerText; i++ {
+		if runes[i] != '@' || (i > 0 && (isNameRune(runes[i-1]) || runes[i-1] == '@')) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isNameRune(runes[end]) {
+			end++
+		}
+
+		next := end
+		for end > i+1 && (runes[end-1] == '.' || runes[end-1] == '-') {
+			end--
+		}
+
+		if n := end - i - 1; n > 0 && n <= MaxLength {
+			found = append(found, Match{Offset: i, Length: end - i, Username: string(runes[i+1 : end])})
+		}
+
+		i = next - 1
+	}
+
+	return found
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Saved is the outcome of storing the mentions of a post or comment. Added
+// lists the users that were not mentioned by a previous version.
+type Saved struct {
+	Spans []Span
+	Added []uuid.UUID
+}
+
+// Mentioner keeps the stored mentions of posts and comments in sync with
+// their content and notifies mentioned users.
+type Mentioner struct {
+	query    *database.Queries
+	notifier *notify.Notifier
+}
+
+func NewMentioner(db *database.Queries, notifier *notify.Notifier) *Mentioner {
+	return &Mentioner{
+		query:    db,
+		notifier: notifier,
+	}
+}
+
+// SavePost replaces the mentions of a post with the ones in content.
+func (m *Mentioner) SavePost(ctx context.Context, postID uuid.UUID, content string) (Saved, error) {
+	previous, err := m.query.GetPostMentionedUsers(ctx, postID)
+
+	if err != nil {
+		return Saved{}, err
+	}
+
+	if err = m.query.DeletePostMentions(ctx, postID); err != nil {
+		return Saved{}, err
+	}
+
+	return m.save(ctx, content, previous, func(s Span) error {
+		return m.query.CreatePostMention(ctx, database.CreatePostMentionParams{
+			PostID:      postID,
+			UserID:      s.UserID,
+			StartOffset: int32(s.Offset),
+			Length:      int32(s.Length),
+		})
+	})
+}
+
+// SaveComment replaces the mentions of a comment with the ones in content.
+func (m *Mentioner) SaveComment(ctx context.Context, commentID uuid.UUID, content string) (Saved, error) {
+	previous, err := m.query.GetCommentMentionedUsers(ctx, commentID)
+
+	if err != nil {
+		return Saved{}, err
+	}
+
+	if err = m.query.DeleteCommentMentions(ctx, commentID); err != nil {
+		return Saved{}, err
+	}
+
+	return m.save(ctx, content, previous, func(s Span) error {
+		return m.query.CreateCommentMention(ctx, database.CreateCommentMentionParams{
+			CommentID:   commentID,
+			UserID:      s.UserID,
+			StartOffset: int32(s.Offset),
+			Length:      int32(s.Length),
+		})
+	})
+}
+
+func (m *Mentioner) save(ctx context.Context, content string, previous []uuid.UUID, create func(Span) error) (Saved, error) {
+	saved := Saved{Spans: []Span{}}
+	matches := Extract(content)
+
+	if len(matches) == 0 {
+		return saved, nil
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, match.Username)
+	}
+
+	users, err := m.query.GetUsersByUsernames(ctx, names)
+
+	if err != nil {
+		return Saved{}, err
+	}
+
+	ids := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		ids[u.Username] = u.ID
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(previous)+len(users))
+	for _, id := range previous {
+		seen[id] = struct{}{}
+	}
+
+	for _, match := range matches {
+		id, ok := ids[match.Username]
+
+		if !ok {
+			continue
+		}
+
+		span := Span{Offset: match.Offset, Length: match.Length, UserID: id, Username: match.Username}
+
+		if err = create(span); err != nil {
+			return Saved{}, err
+		}
+
+		saved.Spans = append(saved.Spans, span)
+
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			saved.Added = append(saved.Added, id)
+		}
+	}
+
+	return saved, nil
+}
+
+// Notify tells users that actor mentioned them on a post. Comment mentions
+// point at the post they were made on too.
+func (m *Mentioner) Notify(ctx context.Context, actor, postID uuid.UUID, users []uuid.UUID) {
+	for _, id := range users {
+		m.notifier.Notify(ctx, notify.Event{
+			Type:      notify.TypeMention,
+			Recipient: id,
+			Actor:     actor,
+			TargetID:  postID,
+		})
+	}
+}
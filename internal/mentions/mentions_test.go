@@ -0,0 +1,48 @@
+package mentions
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	assert := assert2.New(t)
+
+	t.Run("finds mentions with offsets", func(t *testing.T) {
+		got := Extract("@alice and @bob_2, thanks!")
+		assert.Equal([]Match{
+			{Offset: 0, Length: 6, Username: "alice"},
+			{Offset: 11, Length: 6, Username: "bob_2"},
+		}, got)
+	})
+
+	t.Run("offsets count code points", func(t *testing.T) {
+		got := Extract("Привет, @мария")
+		assert.Equal([]Match{{Offset: 8, Length: 6, Username: "мария"}}, got)
+	})
+
+	t.Run("trims trailing punctuation", func(t *testing.T) {
+		got := Extract("ask @john.doe. or @jane-")
+		assert.Equal([]Match{
+			{Offset: 4, Length: 9, Username: "john.doe"},
+			{Offset: 18, Length: 5, Username: "jane"},
+		}, got)
+	})
+
+	t.Run("keeps repeated mentions", func(t *testing.T) {
+		assert.Len(Extract("@a @a"), 2)
+	})
+
+	t.Run("ignores e-mails and lone signs", func(t *testing.T) {
+		assert.Empty(Extract("mail me at bob@example.com, @ alone, @@double, @."))
+	})
+
+	t.Run("ignores too long names", func(t *testing.T) {
+		assert.Empty(Extract("@" + strings.Repeat("a", MaxLength+1)))
+	})
+
+	t.Run("caps the number of mentions", func(t *testing.T) {
+		assert.Len(Extract(strings.Repeat("@user ", MaxPerText+5)), MaxPerText)
+	})
+}
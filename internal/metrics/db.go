@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"poster/internal/lib/sql/sqlhelpers"
+	"time"
+)
+
+// DB times the queries run through it, labelled with the sqlc query name.
+type DB struct {
+	db sqlhelpers.DBTX
+}
+
+func NewDB(db sqlhelpers.DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := d.db.ExecContext(ctx, query, args...)
+	observe(query, start, err)
+
+	return res, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	observe(query, start, err)
+
+	return rows, err
+}
+
+// QueryRowContext counts errors the query itself failed with. An empty
+// result only shows up on Scan and is not an error anyway.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.db.QueryRowContext(ctx, query, args...)
+	observe(query, start, row.Err())
+
+	return row
+}
+
+func observe(query string, start time.Time, err error) {
+	name := sqlhelpers.QueryName(query)
+
+	queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		queryErrors.WithLabelValues(name).Inc()
+	}
+}
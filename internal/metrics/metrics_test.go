@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	assert := assert2.New(t)
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	t.Run("labels requests with the route pattern", func(t *testing.T) {
+		before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/posts/{id}", "418"))
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/posts/1", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/posts/2", nil))
+
+		assert.Equal(before+2, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/posts/{id}", "418")))
+	})
+
+	t.Run("unmatched routes share a label", func(t *testing.T) {
+		before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404"))
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+
+		assert.Equal(before+1, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	})
+}
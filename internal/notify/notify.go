@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"poster/internal/database"
+	"poster/internal/events"
+	"poster/internal/lib/logger/sl"
+	"time"
+)
+
+const (
+	TypePostLike    = "post_like"
+	TypeCommentLike = "comment_like"
+	TypeComment     = "comment"
+	TypeMention     = "mention"
+	TypeFollow      = "follow"
+)
+
+// Email preferences. Each category can be mailed right away, batched into a
+// daily or weekly digest, or not at all.
+const (
+	CategoryReplies  = "replies"
+	CategoryMentions = "mentions"
+	CategoryFollows  = "follows"
+
+	FrequencyOff       = "off"
+	FrequencyImmediate = "immediate"
+	FrequencyDaily     = "daily"
+	FrequencyWeekly    = "weekly"
+)
+
+// Event is something Actor did that Recipient should hear about. TargetID is
+// the post or comment acted upon, or the recipient for follows.
+type Event struct {
+	Type      string
+	Recipient uuid.UUID
+	Actor     uuid.UUID
+	TargetID  uuid.UUID
+}
+
+type Notifier struct {
+	logger *slog.Logger
+	query  *database.Queries
+	hub    *events.Hub
+}
+
+// Pushed is the payload of the "notification" event sent to the recipient's
+// live connections.
+type Pushed struct {
+	ID       uuid.UUID `json:"id"`
+	Type     string    `json:"type"`
+	TargetID uuid.UUID `json:"target_id"`
+	ActorID  uuid.UUID `json:"actor_id"`
+}
+
+func NewNotifier(log *slog.Logger, db *database.Queries, hub *events.Hub) *Notifier {
+	return &Notifier{
+		logger: log,
+		query:  db,
+		hub:    hub,
+	}
+}
+
+// Notify records ev, folding it into the recipient's unread notification
+// about the same target when there is one. Users are not notified about
+// their own actions or about users they blocked or muted. Failures are only
+// logged: a lost notification must not fail the like or comment behind it.
+func (n *Notifier) Notify(ctx context.Context, ev Event) {
+	const op = "notify.Notify"
+
+	if ev.Recipient == ev.Actor {
+		return
+	}
+
+	id, err := n.query.UpsertNotification(ctx, database.UpsertNotificationParams{
+		ID:        uuid.New(),
+		UserID:    ev.Recipient,
+		Type:      ev.Type,
+		TargetID:  ev.TargetID,
+		ActorID:   ev.Actor,
+		CreatedAt: time.Now(),
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	if err != nil {
+		n.logger.ErrorContext(ctx, "Failed to store notification", slog.String("op", op), slog.String("type", ev.Type), sl.Err(err))
+		return
+	}
+
+	err = n.query.AddNotificationActor(ctx, database.AddNotificationActorParams{
+		NotificationID: id,
+		ActorID:        ev.Actor,
+	})
+
+	if err != nil {
+		n.logger.ErrorContext(ctx, "Failed to store notification actor", slog.String("op", op), slog.String("type", ev.Type), sl.Err(err))
+		return
+	}
+
+	n.hub.Publish(events.UserTopic(ev.Recipient.String()), "notification", Pushed{
+		ID:       id,
+		Type:     ev.Type,
+		TargetID: ev.TargetID,
+		ActorID:  ev.Actor,
+	})
+}
+
+// CategoryOf returns the email preference governing a notification type, or
+// "" for types that are never mailed.
+func CategoryOf(notificationType string) string {
+	switch notificationType {
+	case TypeComment:
+		return CategoryReplies
+	case TypeMention:
+		return CategoryMentions
+	case TypeFollow:
+		return CategoryFollows
+	}
+
+	return ""
+}
+
+// Summary renders a notification as text, e.g. "alice and 4 others liked
+// your post".
+func Summary(notificationType, actor string, actorCount int64) string {
+	who := actor
+
+	switch {
+	case actorCount == 2:
+		who = actor + " and 1 other"
+	case actorCount > 2:
+		who = fmt.Sprintf("%s and %d others", actor, actorCount-1)
+	}
+
+	switch notificationType {
+	case TypePostLike:
+		return who + " liked your post"
+	case TypeCommentLike:
+		return who + " liked your comment"
+	case TypeComment:
+		return who + " commented on your post"
+	case TypeMention:
+		return who + " mentioned you"
+	case TypeFollow:
+		return who + " followed you"
+	}
+
+	return who + " interacted with you"
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	assert2 "github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"poster/internal/database"
+	"poster/internal/events"
+	"poster/internal/lib/sql/sqltest"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	assert := assert2.New(t)
+
+	cases := []struct {
+		name  string
+		typ   string
+		count int64
+		want  string
+	}{
+		{"single like", TypePostLike, 1, "alice liked your post"},
+		{"two likes", TypePostLike, 2, "alice and 1 other liked your post"},
+		{"aggregated likes", TypePostLike, 5, "alice and 4 others liked your post"},
+		{"comment like", TypeCommentLike, 1, "alice liked your comment"},
+		{"comment", TypeComment, 3, "alice and 2 others commented on your post"},
+		{"mention", TypeMention, 1, "alice mentioned you"},
+		{"follow", TypeFollow, 1, "alice followed you"},
+		{"unknown type", "poke", 1, "alice interacted with you"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(tc.want, Summary(tc.typ, "alice", tc.count))
+		})
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := context.Background()
+
+	db := sqltest.Open(t)
+	q := database.New(db)
+	n := NewNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)), q, events.NewHub(16, 0))
+
+	recipient, alice, bob := uuid.New(), uuid.New(), uuid.New()
+
+	for _, id := range []uuid.UUID{recipient, alice, bob} {
+		_, err := db.Exec(`INSERT INTO users (id, username, email, password_hash, created_at, updated_at)
+			VALUES ($1, $2, $3, '', now(), now())`, id, id.String(), id.String()+"@example.com")
+		assert.NoError(err)
+	}
+
+	t.Run("new actor makes an emailed notification due again", func(t *testing.T) {
+		target := uuid.New()
+
+		n.Notify(ctx, Event{Recipient: recipient, Actor: alice, Type: TypeComment, TargetID: target})
+
+		var id uuid.UUID
+		assert.NoError(db.QueryRow(`SELECT id FROM notifications WHERE target_id = $1`, target).Scan(&id))
+
+		assert.NoError(q.MarkNotificationsEmailed(ctx, database.MarkNotificationsEmailedParams{
+			EmailedAt: time.Now(),
+			Ids:       []uuid.UUID{id},
+		}))
+
+		n.Notify(ctx, Event{Recipient: recipient, Actor: bob, Type: TypeComment, TargetID: target})
+
+		var (
+			count     int
+			actor     uuid.UUID
+			emailedAt sql.NullTime
+		)
+
+		assert.NoError(db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE target_id = $1`, target).Scan(&count))
+		assert.Equal(1, count)
+
+		assert.NoError(db.QueryRow(`SELECT actor_id, emailed_at FROM notifications WHERE id = $1`, id).Scan(&actor, &emailedAt))
+		assert.Equal(bob, actor)
+		assert.False(emailedAt.Valid)
+	})
+}
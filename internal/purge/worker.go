@@ -0,0 +1,74 @@
+package purge
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"poster/internal/database"
+	"poster/internal/lib/logger/sl"
+	"time"
+)
+
+// Worker hard-deletes posts and comments whose soft deletion is older than
+// the retention window.
+type Worker struct {
+	logger    *slog.Logger
+	query     *database.Queries
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewWorker(log *slog.Logger, db *database.Queries, retention, interval time.Duration) *Worker {
+	return &Worker{
+		logger:    log,
+		query:     db,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges expired content until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) purge(ctx context.Context) {
+	const op = "purge.Worker.purge"
+
+	cutoff := sql.NullTime{Time: time.Now().Add(-w.retention), Valid: true}
+
+	// Comments first: purging a post cascades to whatever comments it still
+	// has, those are not reported separately.
+	comments, err := w.query.PurgeDeletedComments(ctx, cutoff)
+
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.ErrorContext(ctx, "Failed to purge comments", slog.String("op", op), sl.Err(err))
+		}
+		return
+	}
+
+	posts, err := w.query.PurgeDeletedPosts(ctx, cutoff)
+
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.ErrorContext(ctx, "Failed to purge posts", slog.String("op", op), sl.Err(err))
+		}
+		return
+	}
+
+	if posts > 0 || comments > 0 {
+		w.logger.InfoContext(ctx, "Purged deleted content", slog.String("op", op), slog.Int64("posts", posts), slog.Int64("comments", comments))
+	}
+}
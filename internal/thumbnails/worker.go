@@ -0,0 +1,208 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"poster/internal/database"
+	"poster/internal/lib/imaging"
+	"poster/internal/lib/logger/sl"
+	"poster/internal/lib/storage"
+	"time"
+)
+
+const (
+	StatusNone       = "none"
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+
+	maxSourceSize = 64 << 20
+)
+
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Worker generates thumbnails for uploaded images in the background and
+// strips metadata from the stored originals.
+type Worker struct {
+	logger    *slog.Logger
+	query     *database.Queries
+	storage   storage.Storage
+	sizes     []Size
+	interval  time.Duration
+	batchSize int32
+}
+
+func NewWorker(log *slog.Logger, db *database.Queries, store storage.Storage, sizes []Size, interval time.Duration, batchSize int32) *Worker {
+	return &Worker{
+		logger:    log,
+		query:     db,
+		storage:   store,
+		sizes:     sizes,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// StatusFor returns the initial thumbnail status of an upload.
+func StatusFor(mimeType string) string {
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/gif":
+		return StatusPending
+	default:
+		return StatusNone
+	}
+}
+
+// Run polls for pending attachments until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.processBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) {
+	const op = "thumbnails.Worker.processBatch"
+
+	attachments, err := w.query.ClaimPendingThumbnails(ctx, w.batchSize)
+
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.ErrorContext(ctx, "Failed to claim attachments", slog.String("op", op), sl.Err(err))
+		}
+		return
+	}
+
+	for _, a := range attachments {
+		status := StatusDone
+
+		if err = w.process(ctx, a); err != nil {
+			w.logger.WarnContext(ctx, "Failed to generate thumbnails", slog.String("op", op), slog.String("attachment_id", a.ID.String()), sl.Err(err))
+			status = StatusFailed
+		}
+
+		err = w.query.SetThumbnailStatus(ctx, database.SetThumbnailStatusParams{
+			ID:              a.ID,
+			ThumbnailStatus: status,
+		})
+
+		if err != nil {
+			w.logger.ErrorContext(ctx, "Failed to update thumbnail status", slog.String("op", op), sl.Err(err))
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, a database.Attachment) error {
+	data, err := w.read(ctx, a.StorageKey)
+	if err != nil {
+		return err
+	}
+
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	if img, err = w.sanitizeOriginal(ctx, a, data, format, img); err != nil {
+		return err
+	}
+
+	thumbFormat, mimeType, ext := "png", "image/png", ".png"
+
+	if format == "jpeg" {
+		thumbFormat, mimeType, ext = "jpeg", "image/jpeg", ".jpg"
+	}
+
+	for _, size := range w.sizes {
+		thumb := imaging.Fit(img, size.Width, size.Height)
+
+		var buf bytes.Buffer
+		if err = imaging.Encode(&buf, thumb, thumbFormat); err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("thumbnails/%s/%s%s", a.Sha256, size.Name, ext)
+
+		if err = w.storage.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), mimeType); err != nil {
+			return err
+		}
+
+		err = w.query.UpsertThumbnail(ctx, database.UpsertThumbnailParams{
+			AttachmentID: a.ID,
+			Name:         size.Name,
+			StorageKey:   key,
+			MimeType:     mimeType,
+			Width:        int32(thumb.Bounds().Dx()),
+			Height:       int32(thumb.Bounds().Dy()),
+			CreatedAt:    time.Now(),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sanitizeOriginal drops EXIF, text and similar metadata from the stored
+// original. When a JPEG orientation tag would be lost the pixels are rotated
+// instead.
+func (w *Worker) sanitizeOriginal(ctx context.Context, a database.Attachment, data []byte, format string, img image.Image) (image.Image, error) {
+	clean, err := imaging.StripMetadata(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if orientation := imaging.Orientation(data); format == "jpeg" && orientation != 1 {
+		img = imaging.Orient(img, orientation)
+
+		var buf bytes.Buffer
+		if err = imaging.Encode(&buf, img, "jpeg"); err != nil {
+			return nil, err
+		}
+		clean = buf.Bytes()
+	}
+
+	if bytes.Equal(clean, data) {
+		return img, nil
+	}
+
+	if err = w.storage.Put(ctx, a.StorageKey, bytes.NewReader(clean), int64(len(clean)), a.MimeType); err != nil {
+		return nil, err
+	}
+
+	err = w.query.UpdateAttachmentSize(ctx, database.UpdateAttachmentSizeParams{
+		StorageKey: a.StorageKey,
+		SizeBytes:  int64(len(clean)),
+	})
+
+	return img, err
+}
+
+func (w *Worker) read(ctx context.Context, key string) ([]byte, error) {
+	rc, err := w.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxSourceSize))
+}
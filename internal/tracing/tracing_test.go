@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-chi/chi/v5"
+	assert2 "github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	_, err := Setup(context.Background(), Options{})
+	assert2.NoError(t, err)
+
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert2.New(t)
+	recorder := setupRecorder(t)
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	t.Run("names the span after the route and continues the trace", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/posts/1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		if !assert.Len(spans, 1) {
+			return
+		}
+
+		span := spans[0]
+		assert.Equal("GET /posts/{id}", span.Name())
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal("00f067aa0ba902b7", span.Parent().SpanID().String())
+		assert.Equal(codes.Error, span.Status().Code)
+	})
+}
+
+func TestLogHandler(t *testing.T) {
+	assert := assert2.New(t)
+	setupRecorder(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil)))
+
+	t.Run("adds the ids of the span in the context", func(t *testing.T) {
+		buf.Reset()
+
+		ctx, span := Tracer().Start(context.Background(), "test")
+		logger.InfoContext(ctx, "hello")
+		span.End()
+
+		assert.Contains(buf.String(), "trace_id="+span.SpanContext().TraceID().String())
+		assert.Contains(buf.String(), "span_id="+span.SpanContext().SpanID().String())
+	})
+
+	t.Run("leaves records without a span alone", func(t *testing.T) {
+		buf.Reset()
+
+		logger.Info("hello")
+
+		assert.NotContains(buf.String(), "trace_id")
+	})
+}
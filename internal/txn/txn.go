@@ -0,0 +1,40 @@
+// Package txn starts transactions for the sqlc queries. Queries run in a
+// transaction go through the same instrumentation as the ones on the pool.
+package txn
+
+import (
+	"context"
+	"database/sql"
+	"poster/internal/database"
+	"poster/internal/lib/sql/sqlhelpers"
+)
+
+// Wrap instruments the connection queries run on.
+type Wrap func(sqlhelpers.DBTX) sqlhelpers.DBTX
+
+type Runner struct {
+	db   *sql.DB
+	wrap Wrap
+}
+
+// NewRunner returns a Runner whose transactions are wrapped with wrap. A nil
+// wrap leaves them as they are.
+func NewRunner(db *sql.DB, wrap Wrap) *Runner {
+	if wrap == nil {
+		wrap = func(db sqlhelpers.DBTX) sqlhelpers.DBTX { return db }
+	}
+
+	return &Runner{db: db, wrap: wrap}
+}
+
+// Begin starts a transaction and returns queries bound to it. The caller
+// commits or rolls back tx.
+func (r *Runner) Begin(ctx context.Context) (*sql.Tx, *database.Queries, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, database.New(r.wrap(tx)), nil
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+
+CREATE TABLE tags (
+    id UUID PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE post_tags (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX post_tags_tag_id_idx ON post_tags(tag_id);
+
+
+
+-- +goose Down
+DROP TABLE post_tags;
+DROP TABLE tags;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+ALTER TABLE posts ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+
+-- Content written before Markdown support was plain text, keep it as an escaped paragraph.
+UPDATE posts
+SET content_html = '<p>' || replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;') || E'</p>\n';
+
+UPDATE comments
+SET content_html = '<p>' || replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;') || E'</p>\n';
+
+
+
+-- +goose Down
+ALTER TABLE comments DROP COLUMN content_html;
+ALTER TABLE posts DROP COLUMN content_html;
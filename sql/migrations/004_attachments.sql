@@ -0,0 +1,21 @@
+-- +goose Up
+
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NULL REFERENCES posts(id) ON DELETE SET NULL,
+    storage_key TEXT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX attachments_post_id_idx ON attachments(post_id);
+CREATE INDEX attachments_owner_sha256_idx ON attachments(owner_id, sha256);
+
+
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,28 @@
+-- +goose Up
+
+ALTER TABLE attachments ADD COLUMN thumbnail_status VARCHAR(20) NOT NULL DEFAULT 'none';
+ALTER TABLE attachments ADD COLUMN thumbnail_claimed_at TIMESTAMP NULL;
+
+UPDATE attachments SET thumbnail_status = 'pending'
+WHERE mime_type IN ('image/png', 'image/jpeg', 'image/gif');
+
+CREATE INDEX attachments_thumbnail_status_idx ON attachments(thumbnail_status);
+
+CREATE TABLE attachment_thumbnails (
+    attachment_id UUID NOT NULL REFERENCES attachments(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    storage_key TEXT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (attachment_id, name)
+);
+
+
+
+-- +goose Down
+DROP TABLE attachment_thumbnails;
+DROP INDEX attachments_thumbnail_status_idx;
+ALTER TABLE attachments DROP COLUMN thumbnail_claimed_at;
+ALTER TABLE attachments DROP COLUMN thumbnail_status;
@@ -0,0 +1,61 @@
+-- +goose Up
+
+CREATE TABLE post_reactions (
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    emoji VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT unique_post_reaction UNIQUE (user_id, post_id, emoji)
+);
+
+CREATE INDEX post_reactions_post_id_idx ON post_reactions(post_id, emoji);
+
+CREATE TABLE comment_reactions (
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    emoji VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT unique_comment_reaction UNIQUE (user_id, comment_id, emoji)
+);
+
+CREATE INDEX comment_reactions_comment_id_idx ON comment_reactions(comment_id, emoji);
+
+-- Every existing like becomes the default reaction (reactions.Like).
+INSERT INTO post_reactions (id, user_id, post_id, emoji, created_at)
+SELECT id, user_id, post_id, '👍', created_at FROM post_likes;
+
+INSERT INTO comment_reactions (id, user_id, comment_id, emoji, created_at)
+SELECT id, user_id, comment_id, '👍', created_at FROM comment_likes;
+
+DROP TABLE post_likes;
+DROP TABLE comment_likes;
+
+
+
+-- +goose Down
+CREATE TABLE post_likes (
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT unique_post_like UNIQUE (user_id, post_id)
+);
+
+CREATE TABLE comment_likes (
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CONSTRAINT unique_comment_like UNIQUE (user_id, comment_id)
+);
+
+INSERT INTO post_likes (id, user_id, post_id, created_at)
+SELECT id, user_id, post_id, created_at FROM post_reactions WHERE emoji = '👍';
+
+INSERT INTO comment_likes (id, user_id, comment_id, created_at)
+SELECT id, user_id, comment_id, created_at FROM comment_reactions WHERE emoji = '👍';
+
+DROP TABLE comment_reactions;
+DROP TABLE post_reactions;
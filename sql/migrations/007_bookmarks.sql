@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE bookmarks (
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    collection VARCHAR(50) NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT unique_bookmark UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX bookmarks_user_id_idx ON bookmarks(user_id, created_at DESC);
+
+
+
+-- +goose Down
+DROP TABLE bookmarks;
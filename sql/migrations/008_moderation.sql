@@ -0,0 +1,51 @@
+-- +goose Up
+
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP NULL;
+ALTER TABLE posts ADD COLUMN hidden_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP NULL;
+ALTER TABLE comments ADD COLUMN hidden_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type VARCHAR(16) NOT NULL CHECK (target_type IN ('post', 'comment')),
+    target_id UUID NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+    resolved_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- One open report per user and target, re-reporting after a resolution is fine.
+CREATE UNIQUE INDEX reports_open_unique ON reports(reporter_id, target_type, target_id) WHERE status = 'open';
+CREATE INDEX reports_status_idx ON reports(status, created_at);
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY NOT NULL,
+    moderator_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(32) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id UUID NOT NULL,
+    report_id UUID NULL REFERENCES reports(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_actions_target_idx ON moderation_actions(target_type, target_id);
+
+
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE comments DROP COLUMN hidden_by;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_by;
+ALTER TABLE posts DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,20 @@
+-- +goose Up
+
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE posts ADD COLUMN deleted_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE comments ADD COLUMN deleted_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX posts_deleted_at_idx ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX comments_deleted_at_idx ON comments(deleted_at) WHERE deleted_at IS NOT NULL;
+
+
+
+-- +goose Down
+DROP INDEX comments_deleted_at_idx;
+DROP INDEX posts_deleted_at_idx;
+ALTER TABLE comments DROP COLUMN deleted_by;
+ALTER TABLE comments DROP COLUMN deleted_at;
+ALTER TABLE posts DROP COLUMN deleted_by;
+ALTER TABLE posts DROP COLUMN deleted_at;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN suspension_reason;
+ALTER TABLE users DROP COLUMN suspended_until;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Reports filed by the content filter have no reporter.
+ALTER TABLE reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+
+
+-- +goose Down
+DELETE FROM reports WHERE reporter_id IS NULL;
+ALTER TABLE reports ALTER COLUMN reporter_id SET NOT NULL;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+CREATE TABLE notification_settings (
+    user_id UUID PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    replies VARCHAR(16) NOT NULL DEFAULT 'off' CHECK (replies IN ('off', 'immediate', 'daily', 'weekly')),
+    mentions VARCHAR(16) NOT NULL DEFAULT 'off' CHECK (mentions IN ('off', 'immediate', 'daily', 'weekly')),
+    follows VARCHAR(16) NOT NULL DEFAULT 'off' CHECK (follows IN ('off', 'immediate', 'daily', 'weekly')),
+    daily_digest_at TIMESTAMP NULL,
+    weekly_digest_at TIMESTAMP NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE notifications ADD COLUMN emailed_at TIMESTAMP NULL;
+
+
+
+-- +goose Down
+ALTER TABLE notifications DROP COLUMN emailed_at;
+DROP TABLE notification_settings;
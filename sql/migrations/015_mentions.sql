@@ -0,0 +1,29 @@
+-- +goose Up
+
+-- Offsets and lengths count Unicode code points of the content and cover the
+-- leading '@'.
+CREATE TABLE post_mentions (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_offset INT NOT NULL,
+    length INT NOT NULL,
+    PRIMARY KEY (post_id, start_offset)
+);
+
+CREATE INDEX post_mentions_user_id_idx ON post_mentions(user_id);
+
+CREATE TABLE comment_mentions (
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_offset INT NOT NULL,
+    length INT NOT NULL,
+    PRIMARY KEY (comment_id, start_offset)
+);
+
+CREATE INDEX comment_mentions_user_id_idx ON comment_mentions(user_id);
+
+
+
+-- +goose Down
+DROP TABLE comment_mentions;
+DROP TABLE post_mentions;
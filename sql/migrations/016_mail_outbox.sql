@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- Outgoing emails are stored here and delivered by the mail queue worker.
+-- message holds the rendered RFC 5322 message.
+CREATE TABLE mail_outbox (
+    id UUID PRIMARY KEY NOT NULL,
+    recipients TEXT[] NOT NULL,
+    message BYTEA NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    claimed_at TIMESTAMP NULL,
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX mail_outbox_due_idx ON mail_outbox(next_attempt_at) WHERE status = 'pending';
+
+
+
+-- +goose Down
+DROP TABLE mail_outbox;
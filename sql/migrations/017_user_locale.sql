@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Language of the emails sent to the user. Emails used to be Russian only.
+ALTER TABLE users ADD COLUMN locale VARCHAR(8) NOT NULL DEFAULT 'ru';
+
+
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN locale;
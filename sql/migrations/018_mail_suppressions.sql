@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Addresses the mail queue refuses to send to, because mail to them bounced
+-- permanently or the recipient marked it as spam. Emails are stored lowercased.
+CREATE TABLE mail_suppressions (
+    email TEXT PRIMARY KEY NOT NULL,
+    reason VARCHAR(16) NOT NULL CHECK (reason IN ('bounced', 'complained')),
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+
+
+-- +goose Down
+DROP TABLE mail_suppressions;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Users whose email keeps failing are retried later instead of holding up
+-- everyone else.
+ALTER TABLE notification_settings ADD COLUMN digest_failed_at TIMESTAMP NULL;
+
+
+
+-- +goose Down
+ALTER TABLE notification_settings DROP COLUMN digest_failed_at;
@@ -0,0 +1,60 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (
+    id, owner_id, storage_key, file_name, mime_type, size_bytes, sha256, thumbnail_status, created_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments WHERE id = $1;
+
+-- name: GetUnattachedAttachmentByHash :one
+SELECT * FROM attachments
+WHERE owner_id = $1 AND sha256 = $2 AND post_id IS NULL
+LIMIT 1;
+
+-- name: CountAttachableAttachments :one
+SELECT COUNT(*) FROM attachments
+WHERE id = ANY(@ids::uuid[])
+  AND owner_id = @owner_id
+  AND (post_id IS NULL OR post_id = sqlc.narg(post_id));
+
+-- name: AttachToPost :execrows
+UPDATE attachments SET post_id = @post_id
+WHERE id = ANY(@ids::uuid[])
+  AND owner_id = @owner_id
+  AND (post_id IS NULL OR post_id = @post_id);
+
+-- name: DetachFromPost :exec
+UPDATE attachments SET post_id = NULL
+WHERE post_id = @post_id AND NOT (id = ANY(@keep_ids::uuid[]));
+
+
+-- name: ClaimPendingThumbnails :many
+UPDATE attachments
+SET thumbnail_status = 'processing', thumbnail_claimed_at = now()
+WHERE id IN (
+    SELECT id FROM attachments
+    WHERE thumbnail_status = 'pending'
+       OR (thumbnail_status = 'processing' AND thumbnail_claimed_at < now() - interval '10 minutes')
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: SetThumbnailStatus :exec
+UPDATE attachments SET thumbnail_status = $2, thumbnail_claimed_at = NULL WHERE id = $1;
+
+-- name: UpdateAttachmentSize :exec
+UPDATE attachments SET size_bytes = $2 WHERE storage_key = $1;
+
+-- name: UpsertThumbnail :exec
+INSERT INTO attachment_thumbnails (attachment_id, name, storage_key, mime_type, width, height, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (attachment_id, name) DO UPDATE
+SET storage_key = EXCLUDED.storage_key,
+    mime_type = EXCLUDED.mime_type,
+    width = EXCLUDED.width,
+    height = EXCLUDED.height;
+
+-- name: GetThumbnail :one
+SELECT * FROM attachment_thumbnails WHERE attachment_id = $1 AND name = $2;
@@ -0,0 +1,130 @@
+-- name: AddBookmark :one
+INSERT INTO bookmarks (id, user_id, post_id, collection, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) DO UPDATE SET collection = EXCLUDED.collection
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetBookmarkCollections :many
+SELECT collection::text AS name, COUNT(*) AS post_count
+FROM bookmarks
+WHERE user_id = $1 AND collection IS NOT NULL
+GROUP BY collection
+ORDER BY collection;
+
+-- name: GetBookmarkedPosts :many
+SELECT
+    p.id,
+    p.author_id,
+    p.title,
+    p.content,
+    p.content_html,
+    p.created_at,
+    p.updated_at,
+    b.collection,
+    b.created_at AS bookmarked_at,
+    COALESCE(l.like_count, 0) AS like_count,
+    COALESCE(lb.liked_by_user, false) AS liked_by_user,
+    EXISTS(
+        SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = @user_id
+    ) AS bookmarked_by_user,
+    COALESCE((
+        SELECT json_agg(json_build_object('id', u.id, 'username', u.username) ORDER BY lr.created_at DESC)
+        FROM (
+            SELECT user_id, created_at
+            FROM post_reactions
+            WHERE post_id = p.id AND emoji = '👍'
+            ORDER BY created_at DESC
+            LIMIT 1
+        ) AS lr
+                 JOIN users u ON u.id = lr.user_id
+    ), '[]')::json AS liked_by,
+    GREATEST(COALESCE(l.like_count, 0) - 1, 0)::bigint AS liked_by_others,
+    COALESCE((
+        SELECT json_object_agg(rc.emoji, rc.count)
+        FROM (
+            SELECT emoji, COUNT(*) AS count
+            FROM post_reactions
+            WHERE post_id = p.id
+            GROUP BY emoji
+        ) AS rc
+    ), '{}')::json AS reactions,
+    COALESCE((
+        SELECT array_agg(ur.emoji ORDER BY ur.created_at)
+        FROM post_reactions ur
+        WHERE ur.post_id = p.id AND ur.user_id = @user_id
+    ), '{}')::text[] AS user_reactions,
+    COALESCE(cc.comment_count, 0) AS comment_count,
+    COALESCE((
+        SELECT array_agg(tg.name ORDER BY tg.name)
+        FROM post_tags ptg
+                 JOIN tags tg ON tg.id = ptg.tag_id
+        WHERE ptg.post_id = p.id
+    ), '{}')::text[] AS tags,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'id', a.id,
+            'url', '/uploads/' || a.id,
+            'file_name', a.file_name,
+            'mime_type', a.mime_type,
+            'size_bytes', a.size_bytes,
+            'thumbnails', COALESCE((
+                SELECT json_object_agg(th.name, json_build_object(
+                    'url', '/uploads/' || a.id || '/thumbnails/' || th.name,
+                    'width', th.width,
+                    'height', th.height
+                ))
+                FROM attachment_thumbnails th
+                WHERE th.attachment_id = a.id
+            ), '{}')
+        ) ORDER BY a.created_at)
+        FROM attachments a
+        WHERE a.post_id = p.id
+    ), '[]')::json AS attachments,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'offset', pm.start_offset,
+            'length', pm.length,
+            'user_id', pm.user_id,
+            'username', mu.username
+        ) ORDER BY pm.start_offset)
+        FROM post_mentions pm
+                 JOIN users mu ON mu.id = pm.user_id
+        WHERE pm.post_id = p.id
+    ), '[]')::json AS mentions
+FROM posts p
+         JOIN bookmarks b ON b.post_id = p.id AND b.user_id = @user_id
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        COUNT(*) AS like_count
+    FROM post_reactions
+    WHERE emoji = '👍'
+    GROUP BY post_id
+) AS l ON p.id = l.post_id
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        true AS liked_by_user
+    FROM post_reactions
+    WHERE post_reactions.user_id = @user_id AND emoji = '👍'
+) AS lb ON p.id = lb.post_id
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        COUNT(*) AS comment_count
+    FROM comments
+    WHERE deleted_at IS NULL
+    GROUP BY post_id
+) AS cc ON p.id = cc.post_id
+
+WHERE p.hidden_at IS NULL AND p.deleted_at IS NULL
+  AND NOT EXISTS(SELECT 1 FROM blocks bl WHERE bl.blocker_id = p.author_id AND bl.blocked_id = @user_id)
+  AND (sqlc.narg(collection)::text IS NULL OR b.collection = sqlc.narg(collection)::text)
+ORDER BY b.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
@@ -1,32 +1,81 @@
 -- name: CreateComment :one
-INSERT INTO comments (id, post_id, user_id, is_edited, content, created_at, updated_at)
-SELECT $1, $2, $3, $4, $5, $6, $7 WHERE EXISTS(SELECT 1 FROM posts WHERE posts.id = $2) RETURNING *;
+-- Comments the content filter holds are inserted with hidden_at already set.
+INSERT INTO comments (id, post_id, user_id, is_edited, content, content_html, created_at, updated_at, hidden_at)
+SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+WHERE EXISTS(SELECT 1 FROM posts WHERE posts.id = $2 AND posts.deleted_at IS NULL AND posts.hidden_at IS NULL)
+RETURNING *;
 
 -- name: UpdateComment :one
 UPDATE comments
-SET content = $4, is_edited = true, updated_at = now()
-WHERE id = $1 AND user_id = $2 AND post_id = $3
+SET content = $4, content_html = $5, is_edited = true, updated_at = now()
+WHERE id = $1 AND user_id = $2 AND post_id = $3 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: DeleteComment :execrows
-DELETE FROM comments WHERE id = $1 AND post_id = $2 AND user_id = $3;
+UPDATE comments
+SET deleted_at = $4, deleted_by = user_id
+WHERE id = $1 AND post_id = $2 AND user_id = $3 AND deleted_at IS NULL;
+
+-- name: RestoreComment :execrows
+UPDATE comments
+SET deleted_at = NULL, deleted_by = NULL, hidden_at = NULL, hidden_by = NULL
+WHERE id = @id AND (deleted_at IS NULL OR deleted_at > @deleted_after);
+
+-- name: PurgeDeletedComments :execrows
+DELETE FROM comments WHERE deleted_at < $1;
 
 -- name: GetCommentsForPost :many
 SELECT
     c.id,
     c.post_id,
-    c.user_id,
-    c.content,
+    CASE WHEN c.deleted_at IS NULL AND c.hidden_at IS NULL THEN c.user_id END AS user_id,
+    CASE
+        WHEN c.deleted_at IS NOT NULL THEN '[deleted]'
+        WHEN c.hidden_at IS NOT NULL THEN '[removed]'
+        ELSE c.content
+        END::text AS content,
+    CASE
+        WHEN c.deleted_at IS NOT NULL THEN '<p>[deleted]</p>'
+        WHEN c.hidden_at IS NOT NULL THEN '<p>[removed]</p>'
+        ELSE c.content_html
+        END::text AS content_html,
+    (c.deleted_at IS NOT NULL OR c.hidden_at IS NOT NULL)::boolean AS is_deleted,
     c.created_at,
     c.updated_at,
     COALESCE(l.like_count, 0) AS like_count,
-    COALESCE(lb.liked_by_user, false) AS liked_by_user
+    COALESCE(lb.liked_by_user, false) AS liked_by_user,
+    COALESCE((
+        SELECT json_object_agg(rc.emoji, rc.count)
+        FROM (
+            SELECT emoji, COUNT(*) AS count
+            FROM comment_reactions
+            WHERE comment_id = c.id
+            GROUP BY emoji
+        ) AS rc
+    ), '{}')::json AS reactions,
+    COALESCE((
+        SELECT array_agg(ur.emoji ORDER BY ur.created_at)
+        FROM comment_reactions ur
+        WHERE ur.comment_id = c.id AND ur.user_id = $1
+    ), '{}')::text[] AS user_reactions,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'offset', cm.start_offset,
+            'length', cm.length,
+            'user_id', cm.user_id,
+            'username', mu.username
+        ) ORDER BY cm.start_offset)
+        FROM comment_mentions cm
+                 JOIN users mu ON mu.id = cm.user_id
+        WHERE cm.comment_id = c.id AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+    ), '[]')::json AS mentions
 FROM comments c
          LEFT JOIN (
     SELECT
         comment_id,
         COUNT(*) AS like_count
-    FROM comment_likes
+    FROM comment_reactions
+    WHERE emoji = '👍'
     GROUP BY comment_id
 ) l ON c.id = l.comment_id
 
@@ -34,14 +83,27 @@ FROM comments c
     SELECT
         comment_id,
         true AS liked_by_user
-    FROM comment_likes cl
-    WHERE cl.user_id = $1
+    FROM comment_reactions cl
+    WHERE cl.user_id = $1 AND cl.emoji = '👍'
 ) lb ON c.id = lb.comment_id
 
 WHERE c.post_id = $2
+  AND NOT EXISTS(
+    SELECT 1 FROM blocks bl
+    WHERE (bl.blocker_id = c.user_id AND bl.blocked_id = $1)
+       OR (bl.blocker_id = $1 AND bl.blocked_id = c.user_id)
+)
 ORDER BY c.created_at DESC;
 
 
 
 -- name: GetComment :one
+SELECT * FROM comments WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetVisibleComment :one
+SELECT * FROM comments c
+WHERE c.id = $1 AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+  AND EXISTS(SELECT 1 FROM posts p WHERE p.id = c.post_id AND p.deleted_at IS NULL AND p.hidden_at IS NULL);
+
+-- name: GetCommentIncludingDeleted :one
 SELECT * FROM comments WHERE id = $1;
\ No newline at end of file
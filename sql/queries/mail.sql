@@ -0,0 +1,54 @@
+-- name: EnqueueMail :exec
+INSERT INTO mail_outbox (id, recipients, message, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ClaimDueMail :many
+-- next_attempt_at is a TIMESTAMP written from the application clock, so the
+-- current time is passed in as well instead of using the server's now().
+UPDATE mail_outbox
+SET status = 'sending', claimed_at = @now::timestamp, attempts = attempts + 1
+WHERE id IN (
+    SELECT id FROM mail_outbox
+    WHERE (status = 'pending' AND next_attempt_at <= @now::timestamp)
+       OR (status = 'sending' AND claimed_at < @now::timestamp - interval '10 minutes')
+    ORDER BY next_attempt_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkMailSent :exec
+UPDATE mail_outbox
+SET status = 'sent', sent_at = @sent_at::timestamp, claimed_at = NULL, last_error = ''
+WHERE id = @id;
+
+-- name: RetryMail :exec
+UPDATE mail_outbox
+SET status = 'pending', next_attempt_at = @next_attempt_at, claimed_at = NULL, last_error = @last_error
+WHERE id = @id;
+
+-- name: MarkMailDead :exec
+UPDATE mail_outbox
+SET status = 'dead', claimed_at = NULL, last_error = @last_error
+WHERE id = @id;
+
+-- name: DeleteSentMail :execrows
+DELETE FROM mail_outbox WHERE status = 'sent' AND sent_at < @sent_before::timestamp;
+
+-- name: SuppressEmail :exec
+-- A complaint is never downgraded to a bounce.
+INSERT INTO mail_suppressions (email, reason, detail, created_at, updated_at)
+VALUES (lower(@email::text), @reason, @detail, @suppressed_at::timestamp, @suppressed_at::timestamp)
+ON CONFLICT (email) DO UPDATE
+SET reason     = CASE WHEN mail_suppressions.reason = 'complained' THEN mail_suppressions.reason ELSE EXCLUDED.reason END,
+    detail     = EXCLUDED.detail,
+    updated_at = EXCLUDED.updated_at;
+
+-- name: GetSuppressedEmails :many
+SELECT email FROM mail_suppressions WHERE email = ANY(@emails::text[]);
+
+-- name: GetEmailSuppression :one
+SELECT * FROM mail_suppressions WHERE email = lower(@email::text);
+
+-- name: DeleteEmailSuppression :execrows
+DELETE FROM mail_suppressions WHERE email = lower(@email::text);
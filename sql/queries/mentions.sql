@@ -0,0 +1,22 @@
+-- name: GetUsersByUsernames :many
+SELECT id, username FROM users WHERE username = ANY(@usernames::text[]);
+
+-- name: GetPostMentionedUsers :many
+SELECT DISTINCT user_id FROM post_mentions WHERE post_id = $1;
+
+-- name: DeletePostMentions :exec
+DELETE FROM post_mentions WHERE post_id = $1;
+
+-- name: CreatePostMention :exec
+INSERT INTO post_mentions (post_id, user_id, start_offset, length)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetCommentMentionedUsers :many
+SELECT DISTINCT user_id FROM comment_mentions WHERE comment_id = $1;
+
+-- name: DeleteCommentMentions :exec
+DELETE FROM comment_mentions WHERE comment_id = $1;
+
+-- name: CreateCommentMention :exec
+INSERT INTO comment_mentions (comment_id, user_id, start_offset, length)
+VALUES ($1, $2, $3, $4);
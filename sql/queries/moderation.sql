@@ -0,0 +1,88 @@
+-- name: GetUserRole :one
+SELECT role FROM users WHERE id = $1;
+
+-- name: CreateReport :one
+INSERT INTO reports (id, reporter_id, target_type, target_id, reason, details, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: GetReports :many
+SELECT
+    r.*,
+    u.username AS reporter_username,
+    (
+        SELECT COUNT(*)
+        FROM reports same
+        WHERE same.target_type = r.target_type AND same.target_id = r.target_id AND same.status = 'open'
+    ) AS open_reports_for_target
+FROM reports r
+         LEFT JOIN users u ON u.id = r.reporter_id
+WHERE r.status = @status
+  AND (sqlc.narg(target_type)::text IS NULL OR r.target_type = sqlc.narg(target_type)::text)
+ORDER BY r.created_at
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = @status, resolved_by = @resolved_by, resolved_at = @resolved_at
+WHERE id = @id AND status = 'open'
+RETURNING *;
+
+-- name: ResolveTargetReports :execrows
+UPDATE reports
+SET status = @status, resolved_by = @resolved_by, resolved_at = @resolved_at
+WHERE target_type = @target_type AND target_id = @target_id AND status = 'open';
+
+-- name: HidePost :execrows
+-- Held content (hidden_by IS NULL) matches too, so a moderator can confirm a hold.
+UPDATE posts SET hidden_at = $2, hidden_by = $3 WHERE id = $1 AND (hidden_at IS NULL OR hidden_by IS NULL);
+
+-- name: HideComment :execrows
+UPDATE comments SET hidden_at = $2, hidden_by = $3 WHERE id = $1 AND (hidden_at IS NULL OR hidden_by IS NULL);
+
+-- name: DeleteCommentByID :execrows
+UPDATE comments SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, moderator_id, action, target_type, target_id, report_id, note, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT
+    a.*,
+    u.username AS moderator_username
+FROM moderation_actions a
+         LEFT JOIN users u ON u.id = a.moderator_id
+WHERE (sqlc.narg(target_id)::uuid IS NULL OR a.target_id = sqlc.narg(target_id)::uuid)
+ORDER BY a.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+
+-- name: ReleaseHeldPost :execrows
+UPDATE posts SET hidden_at = NULL WHERE id = $1 AND hidden_at IS NOT NULL AND hidden_by IS NULL;
+
+-- name: ReleaseHeldComment :execrows
+UPDATE comments SET hidden_at = NULL WHERE id = $1 AND hidden_at IS NOT NULL AND hidden_by IS NULL;
+
+-- name: CountRecentDuplicates :one
+SELECT (
+    (SELECT COUNT(*)
+     FROM posts p
+     WHERE p.author_id = @author_id
+       AND p.created_at > @since
+       AND p.id <> @exclude_id
+       AND p.deleted_at IS NULL
+       AND lower(btrim(regexp_replace(p.title || ' ' || p.content, '\s+', ' ', 'g'))) = @fingerprint::text)
+    +
+    (SELECT COUNT(*)
+     FROM comments c
+     WHERE c.user_id = @author_id
+       AND c.created_at > @since
+       AND c.id <> @exclude_id
+       AND c.deleted_at IS NULL
+       AND lower(btrim(regexp_replace(c.content, '\s+', ' ', 'g'))) = @fingerprint::text)
+)::bigint AS duplicates;
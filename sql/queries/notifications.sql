@@ -0,0 +1,119 @@
+-- name: UpsertNotification :one
+-- Folding in a new actor makes an already mailed notification due again.
+INSERT INTO notifications (id, user_id, type, target_id, actor_id, created_at, updated_at)
+SELECT @id, @user_id, @type, @target_id, @actor_id, @created_at, @created_at
+WHERE NOT EXISTS(
+    SELECT 1 FROM blocks WHERE blocker_id = @user_id AND blocked_id = @actor_id
+) AND NOT EXISTS(
+    SELECT 1 FROM mutes WHERE muter_id = @user_id AND muted_id = @actor_id
+)
+ON CONFLICT (user_id, type, target_id) WHERE read_at IS NULL
+    DO UPDATE SET actor_id = EXCLUDED.actor_id, updated_at = EXCLUDED.updated_at, emailed_at = NULL
+RETURNING id;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotifications :many
+SELECT
+    n.id,
+    n.type,
+    n.target_id,
+    n.actor_id,
+    u.username AS actor_username,
+    (SELECT COUNT(*) FROM notification_actors na WHERE na.notification_id = n.id) AS actor_count,
+    n.read_at,
+    n.created_at,
+    n.updated_at
+FROM notifications n
+         JOIN users u ON u.id = n.actor_id
+WHERE n.user_id = @user_id
+  AND (NOT @unread_only::bool OR n.read_at IS NULL)
+ORDER BY n.updated_at DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, @read_at::timestamp)
+WHERE id = @id AND user_id = @user_id;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = @read_at::timestamp
+WHERE user_id = @user_id AND read_at IS NULL;
+
+-- name: GetNotificationSettings :one
+SELECT * FROM notification_settings WHERE user_id = $1;
+
+-- name: UpsertNotificationSettings :one
+INSERT INTO notification_settings (user_id, replies, mentions, follows, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+    SET replies = EXCLUDED.replies,
+        mentions = EXCLUDED.mentions,
+        follows = EXCLUDED.follows,
+        updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetDigestRecipients :many
+-- Recipients whose last email failed wait until retry_before and then go last,
+-- so they cannot fill every batch.
+SELECT s.user_id, u.email, u.username, u.locale
+FROM notification_settings s
+         JOIN users u ON u.id = s.user_id
+WHERE u.is_verified = true
+  AND (s.digest_failed_at IS NULL OR s.digest_failed_at <= @retry_before::timestamp)
+  AND NOT EXISTS(SELECT 1 FROM mail_suppressions ms WHERE ms.email = lower(u.email))
+  AND (@frequency::text = 'immediate'
+    OR (@frequency::text = 'daily' AND (s.daily_digest_at IS NULL OR s.daily_digest_at <= @due_before::timestamp))
+    OR (@frequency::text = 'weekly' AND (s.weekly_digest_at IS NULL OR s.weekly_digest_at <= @due_before::timestamp)))
+  AND EXISTS(
+    SELECT 1
+    FROM notifications n
+    WHERE n.user_id = s.user_id
+      AND n.read_at IS NULL
+      AND n.emailed_at IS NULL
+      AND ((n.type = 'comment' AND s.replies = @frequency::text)
+        OR (n.type = 'mention' AND s.mentions = @frequency::text)
+        OR (n.type = 'follow' AND s.follows = @frequency::text))
+)
+ORDER BY s.digest_failed_at NULLS FIRST, s.user_id
+LIMIT @batch_size;
+
+-- name: GetUnsentNotifications :many
+SELECT
+    n.id,
+    n.type,
+    n.target_id,
+    u.username AS actor_username,
+    (SELECT COUNT(*) FROM notification_actors na WHERE na.notification_id = n.id) AS actor_count,
+    n.updated_at
+FROM notifications n
+         JOIN notification_settings s ON s.user_id = n.user_id
+         JOIN users u ON u.id = n.actor_id
+WHERE n.user_id = @user_id
+  AND n.read_at IS NULL
+  AND n.emailed_at IS NULL
+  AND ((n.type = 'comment' AND s.replies = @frequency::text)
+    OR (n.type = 'mention' AND s.mentions = @frequency::text)
+    OR (n.type = 'follow' AND s.follows = @frequency::text))
+ORDER BY n.updated_at DESC
+LIMIT 50;
+
+-- name: MarkNotificationsEmailed :exec
+UPDATE notifications SET emailed_at = @emailed_at::timestamp WHERE id = ANY(@ids::uuid[]);
+
+-- name: SetDigestSent :exec
+UPDATE notification_settings
+SET daily_digest_at  = CASE WHEN @frequency::text = 'daily' THEN @sent_at::timestamp ELSE daily_digest_at END,
+    weekly_digest_at = CASE WHEN @frequency::text = 'weekly' THEN @sent_at::timestamp ELSE weekly_digest_at END,
+    digest_failed_at = NULL
+WHERE user_id = @user_id;
+
+-- name: SetDigestFailed :exec
+UPDATE notification_settings SET digest_failed_at = @failed_at::timestamp WHERE user_id = @user_id;
@@ -1,13 +1,32 @@
 -- name: CreatePost :one
+-- Posts the content filter holds are inserted with hidden_at already set.
 INSERT INTO posts (
-    id, author_id, title, content, created_at, updated_at
-) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+    id, author_id, title, content, content_html, created_at, updated_at, hidden_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
 
--- name: DeletePost :exec
-DELETE FROM posts WHERE id = $1;
+-- name: GetPostByID :one
+SELECT * FROM posts WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetVisiblePost :one
+-- Posts a moderator hid or the content filter holds cannot be interacted with.
+SELECT * FROM posts WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL;
+
+-- name: GetPostIncludingDeleted :one
+SELECT * FROM posts WHERE id = $1;
+
+-- name: DeletePost :execrows
+UPDATE posts SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestorePost :execrows
+UPDATE posts
+SET deleted_at = NULL, deleted_by = NULL, hidden_at = NULL, hidden_by = NULL
+WHERE id = @id AND (deleted_at IS NULL OR deleted_at > @deleted_after);
+
+-- name: PurgeDeletedPosts :execrows
+DELETE FROM posts WHERE deleted_at < $1;
 
 -- name: UpdatePost :one
-UPDATE posts SET title = $2, content = $3, updated_at = $4 WHERE id = $1 RETURNING *;
+UPDATE posts SET title = $2, content = $3, content_html = $4, updated_at = $5 WHERE id = $1 AND deleted_at IS NULL RETURNING *;
 
 
 
@@ -17,17 +36,85 @@ SELECT
     p.author_id,
     p.title,
     p.content,
+    p.content_html,
     p.created_at,
     p.updated_at,
     COALESCE(l.like_count, 0) AS like_count,
     COALESCE(lb.liked_by_user, false) AS liked_by_user,
-    COALESCE(cc.comment_count, 0) AS comment_count
+    EXISTS(
+        SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = @user_id
+    ) AS bookmarked_by_user,
+    COALESCE((
+        SELECT json_agg(json_build_object('id', u.id, 'username', u.username) ORDER BY lr.created_at DESC)
+        FROM (
+            SELECT user_id, created_at
+            FROM post_reactions
+            WHERE post_id = p.id AND emoji = '👍'
+            ORDER BY created_at DESC
+            LIMIT 1
+        ) AS lr
+                 JOIN users u ON u.id = lr.user_id
+    ), '[]')::json AS liked_by,
+    GREATEST(COALESCE(l.like_count, 0) - 1, 0)::bigint AS liked_by_others,
+    COALESCE((
+        SELECT json_object_agg(rc.emoji, rc.count)
+        FROM (
+            SELECT emoji, COUNT(*) AS count
+            FROM post_reactions
+            WHERE post_id = p.id
+            GROUP BY emoji
+        ) AS rc
+    ), '{}')::json AS reactions,
+    COALESCE((
+        SELECT array_agg(ur.emoji ORDER BY ur.created_at)
+        FROM post_reactions ur
+        WHERE ur.post_id = p.id AND ur.user_id = @user_id
+    ), '{}')::text[] AS user_reactions,
+    COALESCE(cc.comment_count, 0) AS comment_count,
+    COALESCE((
+        SELECT array_agg(t.name ORDER BY t.name)
+        FROM post_tags pt
+                 JOIN tags t ON t.id = pt.tag_id
+        WHERE pt.post_id = p.id
+    ), '{}')::text[] AS tags,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'id', a.id,
+            'url', '/uploads/' || a.id,
+            'file_name', a.file_name,
+            'mime_type', a.mime_type,
+            'size_bytes', a.size_bytes,
+            'thumbnails', COALESCE((
+                SELECT json_object_agg(th.name, json_build_object(
+                    'url', '/uploads/' || a.id || '/thumbnails/' || th.name,
+                    'width', th.width,
+                    'height', th.height
+                ))
+                FROM attachment_thumbnails th
+                WHERE th.attachment_id = a.id
+            ), '{}')
+        ) ORDER BY a.created_at)
+        FROM attachments a
+        WHERE a.post_id = p.id
+    ), '[]')::json AS attachments,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'offset', pm.start_offset,
+            'length', pm.length,
+            'user_id', pm.user_id,
+            'username', mu.username
+        ) ORDER BY pm.start_offset)
+        FROM post_mentions pm
+                 JOIN users mu ON mu.id = pm.user_id
+        WHERE pm.post_id = p.id
+    ), '[]')::json AS mentions
 FROM posts p
          LEFT JOIN (
     SELECT
         post_id,
         COUNT(*) AS like_count
-    FROM post_likes
+    FROM post_reactions
+    WHERE emoji = '👍'
     GROUP BY post_id
 ) AS l ON p.id = l.post_id
 
@@ -35,8 +122,8 @@ FROM posts p
     SELECT
         post_id,
         true AS liked_by_user
-    FROM post_likes
-    WHERE post_likes.user_id = $1
+    FROM post_reactions
+    WHERE post_reactions.user_id = @user_id AND emoji = '👍'
 ) AS lb ON p.id = lb.post_id
 
          LEFT JOIN (
@@ -44,10 +131,12 @@ FROM posts p
         post_id,
         COUNT(*) AS comment_count
     FROM comments
+    WHERE deleted_at IS NULL
     GROUP BY post_id
 ) AS cc ON p.id = cc.post_id
 
-WHERE p.id = $1;
+WHERE p.id = @post_id AND p.hidden_at IS NULL AND p.deleted_at IS NULL
+  AND NOT EXISTS(SELECT 1 FROM blocks bl WHERE bl.blocker_id = p.author_id AND bl.blocked_id = @user_id);
 
 
 
@@ -57,17 +146,85 @@ SELECT
     p.author_id,
     p.title,
     p.content,
+    p.content_html,
     p.created_at,
     p.updated_at,
     COALESCE(l.like_count, 0) AS like_count,
     COALESCE(lb.liked_by_user, false) AS liked_by_user,
-    COALESCE(cc.comment_count, 0) AS comment_count
+    EXISTS(
+        SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = @user_id
+    ) AS bookmarked_by_user,
+    COALESCE((
+        SELECT json_agg(json_build_object('id', u.id, 'username', u.username) ORDER BY lr.created_at DESC)
+        FROM (
+            SELECT user_id, created_at
+            FROM post_reactions
+            WHERE post_id = p.id AND emoji = '👍'
+            ORDER BY created_at DESC
+            LIMIT 1
+        ) AS lr
+                 JOIN users u ON u.id = lr.user_id
+    ), '[]')::json AS liked_by,
+    GREATEST(COALESCE(l.like_count, 0) - 1, 0)::bigint AS liked_by_others,
+    COALESCE((
+        SELECT json_object_agg(rc.emoji, rc.count)
+        FROM (
+            SELECT emoji, COUNT(*) AS count
+            FROM post_reactions
+            WHERE post_id = p.id
+            GROUP BY emoji
+        ) AS rc
+    ), '{}')::json AS reactions,
+    COALESCE((
+        SELECT array_agg(ur.emoji ORDER BY ur.created_at)
+        FROM post_reactions ur
+        WHERE ur.post_id = p.id AND ur.user_id = @user_id
+    ), '{}')::text[] AS user_reactions,
+    COALESCE(cc.comment_count, 0) AS comment_count,
+    COALESCE((
+        SELECT array_agg(t.name ORDER BY t.name)
+        FROM post_tags pt
+                 JOIN tags t ON t.id = pt.tag_id
+        WHERE pt.post_id = p.id
+    ), '{}')::text[] AS tags,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'id', a.id,
+            'url', '/uploads/' || a.id,
+            'file_name', a.file_name,
+            'mime_type', a.mime_type,
+            'size_bytes', a.size_bytes,
+            'thumbnails', COALESCE((
+                SELECT json_object_agg(th.name, json_build_object(
+                    'url', '/uploads/' || a.id || '/thumbnails/' || th.name,
+                    'width', th.width,
+                    'height', th.height
+                ))
+                FROM attachment_thumbnails th
+                WHERE th.attachment_id = a.id
+            ), '{}')
+        ) ORDER BY a.created_at)
+        FROM attachments a
+        WHERE a.post_id = p.id
+    ), '[]')::json AS attachments,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'offset', pm.start_offset,
+            'length', pm.length,
+            'user_id', pm.user_id,
+            'username', mu.username
+        ) ORDER BY pm.start_offset)
+        FROM post_mentions pm
+                 JOIN users mu ON mu.id = pm.user_id
+        WHERE pm.post_id = p.id
+    ), '[]')::json AS mentions
 FROM posts p
          LEFT JOIN (
     SELECT
         post_id,
         COUNT(*) AS like_count
-    FROM post_likes
+    FROM post_reactions
+    WHERE emoji = '👍'
     GROUP BY post_id
 ) AS l ON p.id = l.post_id
 
@@ -75,8 +232,8 @@ FROM posts p
     SELECT
         post_id,
         true AS liked_by_user
-    FROM post_likes
-    WHERE post_likes.user_id = $1
+    FROM post_reactions
+    WHERE post_reactions.user_id = @user_id AND emoji = '👍'
 ) AS lb ON p.id = lb.post_id
 
          LEFT JOIN (
@@ -84,6 +241,15 @@ FROM posts p
         post_id,
         COUNT(*) AS comment_count
     FROM comments
+    WHERE deleted_at IS NULL
     GROUP BY post_id
-) AS cc ON p.id = cc.post_id;
+) AS cc ON p.id = cc.post_id
+
+WHERE p.hidden_at IS NULL AND p.deleted_at IS NULL
+  AND NOT EXISTS(
+    SELECT 1 FROM blocks bl
+    WHERE (bl.blocker_id = p.author_id AND bl.blocked_id = @user_id)
+       OR (bl.blocker_id = @user_id AND bl.blocked_id = p.author_id)
+)
+  AND NOT EXISTS(SELECT 1 FROM mutes mu WHERE mu.muter_id = @user_id AND mu.muted_id = p.author_id);
 
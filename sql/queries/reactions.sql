@@ -0,0 +1,39 @@
+-- name: AddPostReaction :execrows
+INSERT INTO post_reactions (id, user_id, post_id, emoji, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id, emoji) DO NOTHING;
+
+-- name: RemovePostReaction :execrows
+DELETE FROM post_reactions
+WHERE user_id = $1 AND post_id = $2 AND emoji = $3;
+
+-- name: AddCommentReaction :execrows
+INSERT INTO comment_reactions (id, user_id, comment_id, emoji, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, comment_id, emoji) DO NOTHING;
+
+-- name: RemoveCommentReaction :execrows
+DELETE FROM comment_reactions
+WHERE user_id = $1 AND comment_id = $2 AND emoji = $3;
+
+-- name: GetPostLikers :many
+SELECT u.id, u.username, r.created_at AS liked_at
+FROM post_reactions r
+         JOIN users u ON u.id = r.user_id
+WHERE r.post_id = @post_id AND r.emoji = '👍'
+ORDER BY r.created_at DESC, u.id
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountPostLikes :one
+SELECT COUNT(*) FROM post_reactions WHERE post_id = $1 AND emoji = '👍';
+
+-- name: GetCommentLikers :many
+SELECT u.id, u.username, r.created_at AS liked_at
+FROM comment_reactions r
+         JOIN users u ON u.id = r.user_id
+WHERE r.comment_id = @comment_id AND r.emoji = '👍'
+ORDER BY r.created_at DESC, u.id
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountCommentLikes :one
+SELECT COUNT(*) FROM comment_reactions WHERE comment_id = $1 AND emoji = '👍';
@@ -0,0 +1,63 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlocked :one
+SELECT EXISTS(SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2);
+
+-- name: GetBlockedUsers :many
+SELECT u.id, u.username, b.created_at AS blocked_at
+FROM blocks b
+         JOIN users u ON u.id = b.blocked_id
+WHERE b.blocker_id = @user_id
+ORDER BY b.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: MuteUser :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT u.id, u.username, m.created_at AS muted_at
+FROM mutes m
+         JOIN users u ON u.id = m.muted_id
+WHERE m.muter_id = @user_id
+ORDER BY m.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @user_a AND followee_id = @user_b)
+   OR (follower_id = @user_b AND followee_id = @user_a);
+
+-- name: GetFollowers :many
+SELECT u.id, u.username, f.created_at AS followed_at
+FROM follows f
+         JOIN users u ON u.id = f.follower_id
+WHERE f.followee_id = @user_id
+ORDER BY f.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: GetFollowing :many
+SELECT u.id, u.username, f.created_at AS followed_at
+FROM follows f
+         JOIN users u ON u.id = f.followee_id
+WHERE f.follower_id = @user_id
+ORDER BY f.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
@@ -0,0 +1,144 @@
+-- name: UpsertTag :one
+INSERT INTO tags (id, name, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: AddPostTag :exec
+INSERT INTO post_tags (post_id, tag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeletePostTags :exec
+DELETE FROM post_tags WHERE post_id = $1;
+
+-- name: GetTagByName :one
+SELECT * FROM tags WHERE name = $1;
+
+-- name: GetPopularTags :many
+SELECT
+    t.name,
+    COUNT(pt.post_id) AS post_count
+FROM tags t
+         JOIN post_tags pt ON pt.tag_id = t.id
+GROUP BY t.id, t.name
+ORDER BY post_count DESC, t.name
+LIMIT $1;
+
+-- name: GetPostsByTag :many
+SELECT
+    p.id,
+    p.author_id,
+    p.title,
+    p.content,
+    p.content_html,
+    p.created_at,
+    p.updated_at,
+    COALESCE(l.like_count, 0) AS like_count,
+    COALESCE(lb.liked_by_user, false) AS liked_by_user,
+    EXISTS(
+        SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = @user_id
+    ) AS bookmarked_by_user,
+    COALESCE((
+        SELECT json_agg(json_build_object('id', u.id, 'username', u.username) ORDER BY lr.created_at DESC)
+        FROM (
+            SELECT user_id, created_at
+            FROM post_reactions
+            WHERE post_id = p.id AND emoji = '👍'
+            ORDER BY created_at DESC
+            LIMIT 1
+        ) AS lr
+                 JOIN users u ON u.id = lr.user_id
+    ), '[]')::json AS liked_by,
+    GREATEST(COALESCE(l.like_count, 0) - 1, 0)::bigint AS liked_by_others,
+    COALESCE((
+        SELECT json_object_agg(rc.emoji, rc.count)
+        FROM (
+            SELECT emoji, COUNT(*) AS count
+            FROM post_reactions
+            WHERE post_id = p.id
+            GROUP BY emoji
+        ) AS rc
+    ), '{}')::json AS reactions,
+    COALESCE((
+        SELECT array_agg(ur.emoji ORDER BY ur.created_at)
+        FROM post_reactions ur
+        WHERE ur.post_id = p.id AND ur.user_id = @user_id
+    ), '{}')::text[] AS user_reactions,
+    COALESCE(cc.comment_count, 0) AS comment_count,
+    COALESCE((
+        SELECT array_agg(tg.name ORDER BY tg.name)
+        FROM post_tags ptg
+                 JOIN tags tg ON tg.id = ptg.tag_id
+        WHERE ptg.post_id = p.id
+    ), '{}')::text[] AS tags,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'id', a.id,
+            'url', '/uploads/' || a.id,
+            'file_name', a.file_name,
+            'mime_type', a.mime_type,
+            'size_bytes', a.size_bytes,
+            'thumbnails', COALESCE((
+                SELECT json_object_agg(th.name, json_build_object(
+                    'url', '/uploads/' || a.id || '/thumbnails/' || th.name,
+                    'width', th.width,
+                    'height', th.height
+                ))
+                FROM attachment_thumbnails th
+                WHERE th.attachment_id = a.id
+            ), '{}')
+        ) ORDER BY a.created_at)
+        FROM attachments a
+        WHERE a.post_id = p.id
+    ), '[]')::json AS attachments,
+    COALESCE((
+        SELECT json_agg(json_build_object(
+            'offset', pm.start_offset,
+            'length', pm.length,
+            'user_id', pm.user_id,
+            'username', mu.username
+        ) ORDER BY pm.start_offset)
+        FROM post_mentions pm
+                 JOIN users mu ON mu.id = pm.user_id
+        WHERE pm.post_id = p.id
+    ), '[]')::json AS mentions
+FROM posts p
+         JOIN post_tags pt ON pt.post_id = p.id
+         JOIN tags t ON t.id = pt.tag_id AND t.name = @tag_name
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        COUNT(*) AS like_count
+    FROM post_reactions
+    WHERE emoji = '👍'
+    GROUP BY post_id
+) AS l ON p.id = l.post_id
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        true AS liked_by_user
+    FROM post_reactions
+    WHERE post_reactions.user_id = @user_id AND emoji = '👍'
+) AS lb ON p.id = lb.post_id
+
+         LEFT JOIN (
+    SELECT
+        post_id,
+        COUNT(*) AS comment_count
+    FROM comments
+    WHERE deleted_at IS NULL
+    GROUP BY post_id
+) AS cc ON p.id = cc.post_id
+
+WHERE p.hidden_at IS NULL AND p.deleted_at IS NULL
+  AND NOT EXISTS(
+    SELECT 1 FROM blocks bl
+    WHERE (bl.blocker_id = p.author_id AND bl.blocked_id = @user_id)
+       OR (bl.blocker_id = @user_id AND bl.blocked_id = p.author_id)
+)
+  AND NOT EXISTS(SELECT 1 FROM mutes mu WHERE mu.muter_id = @user_id AND mu.muted_id = p.author_id)
+ORDER BY p.created_at DESC
+LIMIT @page_limit OFFSET @page_offset;
@@ -3,8 +3,8 @@ SELECT * FROM users;
 
 -- name: CreateUser :one
 INSERT INTO users (
-    id, username, email, password_hash, created_at, updated_at, verify_code
-) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+    id, username, email, password_hash, created_at, updated_at, verify_code, locale
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
@@ -20,3 +20,19 @@ UPDATE users SET refresh_token = $1 WHERE id = $2;
 
 -- name: DeleteUserByEmail :exec
 DELETE FROM users WHERE email = $1;
+
+-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_until = $2, suspension_reason = $3, refresh_token = NULL
+WHERE id = $1;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_until = NULL, suspension_reason = ''
+WHERE id = $1 AND suspended_until IS NOT NULL;
+
+-- name: GetUserSuspension :one
+SELECT suspended_until, suspension_reason FROM users WHERE id = $1;
+
+-- name: SetUserLocale :execrows
+UPDATE users SET locale = $2, updated_at = $3 WHERE id = $1;